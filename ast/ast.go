@@ -10,6 +10,8 @@ import (
 type Node interface {
 	// TokenLiteral returns token.Literal
 	TokenLiteral() string
+	// Pos returns the position of the node in the source.
+	Pos() (row, col int)
 	fmt.Stringer
 }
 
@@ -34,6 +36,13 @@ func (p *Program) TokenLiteral() string {
 	return ""
 }
 
+func (p *Program) Pos() (int, int) {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return 0, 0
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -52,6 +61,7 @@ var _ Statement = (*LetStatement)(nil)
 
 func (s *LetStatement) statementNode()       {}
 func (s *LetStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *LetStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
 func (s *LetStatement) String() string {
 	var out bytes.Buffer
 	fmt.Fprintf(&out, "%s %s = ", s.TokenLiteral(), s.Name.String())
@@ -62,6 +72,21 @@ func (s *LetStatement) String() string {
 	return out.String()
 }
 
+// ExportStatement is a LetStatement whose binding is exported from a module.
+type ExportStatement struct {
+	Token     token.Token // token.EXPORT
+	Statement *LetStatement
+}
+
+var _ Statement = (*ExportStatement)(nil)
+
+func (s *ExportStatement) statementNode()       {}
+func (s *ExportStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ExportStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *ExportStatement) String() string {
+	return fmt.Sprintf("%s %s", s.TokenLiteral(), s.Statement.String())
+}
+
 type ReturnStatement struct {
 	Token       token.Token // token.RETURN
 	ReturnValue Expression
@@ -71,6 +96,7 @@ var _ Statement = (*ReturnStatement)(nil)
 
 func (s *ReturnStatement) statementNode()       {}
 func (s *ReturnStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ReturnStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
 func (s *ReturnStatement) String() string {
 	var out bytes.Buffer
 	fmt.Fprintf(&out, "%s ", s.TokenLiteral())
@@ -89,6 +115,7 @@ var _ Statement = (*ExpressionStatement)(nil)
 
 func (s *ExpressionStatement) statementNode()       {}
 func (s *ExpressionStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ExpressionStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
 func (s *ExpressionStatement) String() string {
 	if s.Expression != nil {
 		return s.Expression.String()
@@ -105,6 +132,7 @@ var _ Expression = (*Identifier)(nil)
 
 func (e *Identifier) expressionNode()      {}
 func (e *Identifier) TokenLiteral() string { return e.Token.Literal }
+func (e *Identifier) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *Identifier) String() string       { return e.Value }
 
 type IntegerLiteral struct {
@@ -116,8 +144,21 @@ var _ Expression = (*IntegerLiteral)(nil)
 
 func (e *IntegerLiteral) expressionNode()      {}
 func (e *IntegerLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *IntegerLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *IntegerLiteral) String() string       { return e.Token.Literal }
 
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+var _ Expression = (*FloatLiteral)(nil)
+
+func (e *FloatLiteral) expressionNode()      {}
+func (e *FloatLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *FloatLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *FloatLiteral) String() string       { return e.Token.Literal }
+
 type BooleanLiteral struct {
 	Token token.Token
 	Value bool
@@ -127,6 +168,7 @@ var _ Expression = (*BooleanLiteral)(nil)
 
 func (e *BooleanLiteral) expressionNode()      {}
 func (e *BooleanLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *BooleanLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *BooleanLiteral) String() string       { return e.Token.Literal }
 
 type PrefixExpression struct {
@@ -139,6 +181,7 @@ var _ Expression = (*PrefixExpression)(nil)
 
 func (e *PrefixExpression) expressionNode()      {}
 func (e *PrefixExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *PrefixExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *PrefixExpression) String() string {
 	return fmt.Sprintf("(%s%s)", e.Operator, e.Right.String())
 }
@@ -153,10 +196,29 @@ var _ Expression = (*InfixExpression)(nil)
 
 func (e *InfixExpression) expressionNode()      {}
 func (e *InfixExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *InfixExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *InfixExpression) String() string {
 	return fmt.Sprintf("(%s %s %s)", e.Left.String(), e.Operator, e.Right.String())
 }
 
+// AssignExpression assigns Value to Target, an Identifier or an IndexExpression.
+// Operator is "=" or a compound operator such as "+=".
+type AssignExpression struct {
+	Token    token.Token // the operator
+	Operator string
+	Target   Expression
+	Value    Expression
+}
+
+var _ Expression = (*AssignExpression)(nil)
+
+func (e *AssignExpression) expressionNode()      {}
+func (e *AssignExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *AssignExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *AssignExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.Target.String(), e.Operator, e.Value.String())
+}
+
 type IfExpression struct {
 	Token                    token.Token
 	Condition                Expression
@@ -167,6 +229,7 @@ var _ Expression = (*IfExpression)(nil)
 
 func (e *IfExpression) expressionNode()      {}
 func (e *IfExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *IfExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *IfExpression) String() string {
 	var out bytes.Buffer
 	fmt.Fprintf(&out, "if%s %s", e.Condition.String(), e.Consequence.String())
@@ -176,6 +239,101 @@ func (e *IfExpression) String() string {
 	return out.String()
 }
 
+type WhileStatement struct {
+	Token     token.Token // token.WHILE
+	Condition Expression
+	Body      *BlockStatement
+}
+
+var _ Statement = (*WhileStatement)(nil)
+
+func (s *WhileStatement) statementNode()       {}
+func (s *WhileStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *WhileStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *WhileStatement) String() string {
+	return fmt.Sprintf("while%s %s", s.Condition.String(), s.Body.String())
+}
+
+// ForStatement binds each element of Iterable to Variable and runs Body.
+type ForStatement struct {
+	Token    token.Token // token.FOR
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+var _ Statement = (*ForStatement)(nil)
+
+func (s *ForStatement) statementNode()       {}
+func (s *ForStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ForStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *ForStatement) String() string {
+	return fmt.Sprintf("for (%s in %s) %s", s.Variable.String(), s.Iterable.String(), s.Body.String())
+}
+
+type BreakStatement struct {
+	Token token.Token // token.BREAK
+}
+
+var _ Statement = (*BreakStatement)(nil)
+
+func (s *BreakStatement) statementNode()       {}
+func (s *BreakStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *BreakStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *BreakStatement) String() string       { return s.Token.Literal }
+
+type ContinueStatement struct {
+	Token token.Token // token.CONTINUE
+}
+
+var _ Statement = (*ContinueStatement)(nil)
+
+func (s *ContinueStatement) statementNode()       {}
+func (s *ContinueStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ContinueStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *ContinueStatement) String() string       { return s.Token.Literal }
+
+// TryStatement runs Block and then Catch if Block fails, and Finally in any case.
+// Catch or Finally may be nil but not both.
+type TryStatement struct {
+	Token   token.Token // token.TRY
+	Block   *BlockStatement
+	Param   *Identifier // the caught error; nil if Catch is nil
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+var _ Statement = (*TryStatement)(nil)
+
+func (s *TryStatement) statementNode()       {}
+func (s *TryStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *TryStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *TryStatement) String() string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "try %s", s.Block.String())
+	if s.Catch != nil {
+		fmt.Fprintf(&out, " catch (%s) %s", s.Param.String(), s.Catch.String())
+	}
+	if s.Finally != nil {
+		fmt.Fprintf(&out, " finally %s", s.Finally.String())
+	}
+	return out.String()
+}
+
+type ThrowStatement struct {
+	Token token.Token // token.THROW
+	Value Expression
+}
+
+var _ Statement = (*ThrowStatement)(nil)
+
+func (s *ThrowStatement) statementNode()       {}
+func (s *ThrowStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *ThrowStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
+func (s *ThrowStatement) String() string {
+	return fmt.Sprintf("throw %s", s.Value.String())
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -185,6 +343,7 @@ var _ Statement = (*BlockStatement)(nil)
 
 func (s *BlockStatement) statementNode()       {}
 func (s *BlockStatement) TokenLiteral() string { return s.Token.Literal }
+func (s *BlockStatement) Pos() (int, int)      { return s.Token.Row, s.Token.Col }
 func (s *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range s.Statements {
@@ -195,7 +354,10 @@ func (s *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
+	Name       string // set if bound by a LetStatement
 	Parameters []*Identifier
+	Defaults   []Expression // Defaults[i] is the default value of Parameters[i] or nil
+	Rest       *Identifier  // collects extra arguments if not nil
 	Body       *BlockStatement
 }
 
@@ -203,16 +365,45 @@ var _ Expression = (*FunctionLiteral)(nil)
 
 func (e *FunctionLiteral) expressionNode()      {}
 func (e *FunctionLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *FunctionLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *FunctionLiteral) String() string {
+	return fmt.Sprintf("fn (%s) %s", FormatParameters(e.Parameters, e.Defaults, e.Rest), e.Body)
+}
+
+// MacroLiteral is a macro like "macro(a, b) { quote(...) }".
+type MacroLiteral struct {
+	Token      token.Token // token.MACRO
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+var _ Expression = (*MacroLiteral)(nil)
+
+func (e *MacroLiteral) expressionNode()      {}
+func (e *MacroLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *MacroLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *MacroLiteral) String() string {
+	return fmt.Sprintf("%s(%s) %s", e.TokenLiteral(), FormatParameters(e.Parameters, nil, nil), e.Body)
+}
+
+// FormatParameters formats parameters of a function like "a, b = 1, ...c".
+func FormatParameters(params []*Identifier, defaults []Expression, rest *Identifier) string {
 	var out bytes.Buffer
-	fmt.Fprint(&out, "fn (")
-	for i, p := range e.Parameters {
+	for i, p := range params {
 		fmt.Fprint(&out, p)
-		if i+1 != len(e.Parameters) {
+		if i < len(defaults) && defaults[i] != nil {
+			fmt.Fprintf(&out, " = %s", defaults[i])
+		}
+		if i+1 != len(params) {
+			fmt.Fprint(&out, ", ")
+		}
+	}
+	if rest != nil {
+		if len(params) != 0 {
 			fmt.Fprint(&out, ", ")
 		}
+		fmt.Fprintf(&out, "...%s", rest)
 	}
-	fmt.Fprintf(&out, ") %s", e.Body)
 	return out.String()
 }
 
@@ -226,6 +417,7 @@ var _ Expression = (*CallExpression)(nil)
 
 func (e *CallExpression) expressionNode()      {}
 func (e *CallExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *CallExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *CallExpression) String() string {
 	var out bytes.Buffer
 	fmt.Fprintf(&out, "%s(", e.Function.String())
@@ -248,6 +440,7 @@ var _ Expression = (*StringLiteral)(nil)
 
 func (e *StringLiteral) expressionNode()      {}
 func (e *StringLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *StringLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *StringLiteral) String() string       { return e.Value }
 
 type ArrayLiteral struct {
@@ -259,6 +452,7 @@ var _ Expression = (*ArrayLiteral)(nil)
 
 func (e *ArrayLiteral) expressionNode()      {}
 func (e *ArrayLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *ArrayLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *ArrayLiteral) String() string {
 	var out bytes.Buffer
 	fmt.Fprint(&out, "[")
@@ -282,6 +476,66 @@ var _ Expression = (*IndexExpression)(nil)
 
 func (e *IndexExpression) expressionNode()      {}
 func (e *IndexExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *IndexExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
 func (e *IndexExpression) String() string {
 	return fmt.Sprintf("(%s[%s])", e.Left.String(), e.Index.String())
 }
+
+// MemberExpression reads the member named Member from Object as in "m.name".
+type MemberExpression struct {
+	Token  token.Token // token.DOT
+	Object Expression
+	Member *Identifier
+}
+
+var _ Expression = (*MemberExpression)(nil)
+
+func (e *MemberExpression) expressionNode()      {}
+func (e *MemberExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *MemberExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *MemberExpression) String() string {
+	return fmt.Sprintf("(%s.%s)", e.Object.String(), e.Member.String())
+}
+
+// ImportExpression evaluates the module at Path as in import("path/to/mod").
+type ImportExpression struct {
+	Token token.Token // token.IMPORT
+	Path  Expression
+}
+
+var _ Expression = (*ImportExpression)(nil)
+
+func (e *ImportExpression) expressionNode()      {}
+func (e *ImportExpression) TokenLiteral() string { return e.Token.Literal }
+func (e *ImportExpression) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *ImportExpression) String() string {
+	return fmt.Sprintf("%s(%s)", e.TokenLiteral(), e.Path.String())
+}
+
+type HashLiteral struct {
+	Token token.Token // "{"
+	Pairs []HashPair  // in source order
+}
+
+// HashPair is a key-value pair in a HashLiteral.
+type HashPair struct {
+	Key, Value Expression
+}
+
+var _ Expression = (*HashLiteral)(nil)
+
+func (e *HashLiteral) expressionNode()      {}
+func (e *HashLiteral) TokenLiteral() string { return e.Token.Literal }
+func (e *HashLiteral) Pos() (int, int)      { return e.Token.Row, e.Token.Col }
+func (e *HashLiteral) String() string {
+	var out bytes.Buffer
+	fmt.Fprint(&out, "{")
+	for i, pair := range e.Pairs {
+		fmt.Fprintf(&out, "%s: %s", pair.Key, pair.Value)
+		if i+1 != len(e.Pairs) {
+			fmt.Fprint(&out, ", ")
+		}
+	}
+	fmt.Fprint(&out, "}")
+	return out.String()
+}
@@ -0,0 +1,77 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/token"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+// oneToTwo replaces integer literal 1 with 2.
+func oneToTwo(node ast.Node) ast.Node {
+	if i, ok := node.(*ast.IntegerLiteral); ok && i.Value == 1 {
+		return &ast.IntegerLiteral{Token: token.New(token.INT, "2", i.Token.Row, i.Token.Col), Value: 2}
+	}
+	return node
+}
+
+func TestModify(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"1", "2"},
+		{"1 + 3; -1", "(2 + 3)(-2)"},
+		{"let x = 1; return 1;", "let x = 2;return 2"},
+		{"if (1) { 1 } else { 1 }", "if2 2else 2"},
+		{"fn(a, b = 1) { 1 }", "fn (a, b = 2) 2"},
+		{"f(1, [1, {1: 1}])[1]", "(f(2, [2, {2: 2}])[2])"},
+		{"while (1) { x = 1 }", "while2 (x = 2)"},
+		{"for (x in [1]) { 1 }", "for (x in [2]) 2"},
+		{"export let x = import(1).y;", "export let x = (import(2).y);"},
+		{"macro(x) { 1 }", "macro(x) 2"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			got := ast.Modify(parse(t, c.input), oneToTwo)
+			if got.String() != c.want {
+				t.Errorf("want=%s got=%s", c.want, got.String())
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	inputs := []string{
+		"let f = fn(a, b = 1, ...c) { if (a) { 1 } else { [1, {1: 1}][1] } };",
+		"for (x in [1]) { while (1) { x += 1; break; } }",
+		"export let x = import(1).y; macro(x) { 1 };",
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			program := parse(t, input)
+			want := program.String()
+			c := ast.Copy(program)
+			modified := ast.Modify(c, oneToTwo)
+			if program.String() != want {
+				t.Errorf("original modified want=%s got=%s", want, program.String())
+			}
+			if modified.String() == want {
+				t.Errorf("copy not modified got=%s", modified.String())
+			}
+		})
+	}
+}
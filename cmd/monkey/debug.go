@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ebiiim/monkey/debug"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/repl"
+	"github.com/ebiiim/monkey/script"
+)
+
+func runDebug(name string, args []string, cfg repl.Config) int {
+	p, err := ioutil.ReadFile(name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	d := debug.New(name, string(p), os.Stdin, os.Stdout, evaluator.Options{
+		CheckedArithmetic: cfg.CheckedArithmetic,
+		Dir:               filepath.Dir(name),
+		File:              filepath.Base(name),
+		ModulePath:        cfg.ModulePath,
+		NoPrelude:         cfg.NoPrelude,
+	})
+	if _, err := d.Run(args); err != nil {
+		script.PrintError(os.Stderr, name, string(p), err)
+		return exitError
+	}
+	return exitOK
+}
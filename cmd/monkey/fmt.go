@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/format"
+	"github.com/ebiiim/monkey/script"
+)
+
+const fmtUsage = `Usage:
+	monkey fmt [-w] [-d] [PATHS...]
+
+Formats Monkey source files, or stdin if no PATHS are given.
+Directories are searched for *` + evaluator.ModuleExt + ` files.
+The formatted source is printed unless -w or -d is set.
+
+Flags:
+`
+
+// runFmt implements "monkey fmt".
+func runFmt(args []string) int {
+	fs := flag.NewFlagSet("monkey fmt", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), fmtUsage)
+		fs.PrintDefaults()
+	}
+	write := fs.Bool("w", false, "write the result to the source file instead of stdout")
+	showDiff := fs.Bool("d", false, "print diffs instead of the formatted source")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "cannot use -w with stdin")
+			return exitUsage
+		}
+		src, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return fmtSource("<stdin>", src, false, *showDiff)
+	}
+	code := exitOK
+	for _, path := range fs.Args() {
+		err := filepath.Walk(path, func(name string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			// files given explicitly are formatted whatever their extension is
+			if fi.IsDir() || (name != path && filepath.Ext(name) != evaluator.ModuleExt) {
+				return nil
+			}
+			src, err := ioutil.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			if c := fmtSource(name, src, *write, *showDiff); c != exitOK {
+				code = c
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			code = exitError
+		}
+	}
+	return code
+}
+
+func fmtSource(name string, src []byte, write, showDiff bool) int {
+	out, err := format.Source(src)
+	if err != nil {
+		script.PrintError(os.Stderr, name, string(src), err)
+		return exitError
+	}
+	if !write && !showDiff {
+		os.Stdout.Write(out)
+		return exitOK
+	}
+	if bytes.Equal(src, out) {
+		return exitOK
+	}
+	if write {
+		fi, err := os.Stat(name)
+		if err == nil {
+			err = ioutil.WriteFile(name, out, fi.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+	}
+	if showDiff {
+		fmt.Print(diff(name, src, out))
+	}
+	return exitOK
+}
+
+// diffContext is the number of unchanged lines around changes in diffs.
+const diffContext = 3
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// diff returns the changes from a to b in the unified format.
+func diff(name string, a, b []byte) string {
+	x, y := splitLines(a), splitLines(b)
+	// lcs[i][j] is the length of the longest common subsequence of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			edits = append(edits, edit{' ', x[i]})
+			i, j = i+1, j+1
+		case j == len(y) || (i < len(x) && lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', x[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', y[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", name, name)
+	aLine, bLine := 0, 0 // lines before edits[k]
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			aLine, bLine, k = aLine+1, bLine+1, k+1
+			continue
+		}
+		// a hunk from the context before edits[k] to the context after the last change close to it
+		start := k - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end, unchanged := k, 0
+		for m := k; m < len(edits) && unchanged <= 2*diffContext; m++ {
+			if edits[m].op == ' ' {
+				unchanged++
+			} else {
+				end, unchanged = m, 0
+			}
+		}
+		end += diffContext + 1
+		if end > len(edits) {
+			end = len(edits)
+		}
+		aStart, bStart := aLine-(k-start), bLine-(k-start)
+		var aCount, bCount int
+		var body strings.Builder
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				aCount++
+			}
+			if e.op != '-' {
+				bCount++
+			}
+			fmt.Fprintf(&body, "%c%s\n", e.op, e.line)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(aStart, aCount), hunkRange(bStart, bCount), body.String())
+		for _, e := range edits[k:end] {
+			if e.op != '+' {
+				aLine++
+			}
+			if e.op != '-' {
+				bLine++
+			}
+		}
+		k = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines after start as "start+1,count" where an empty range refers to the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(p []byte) []string {
+	s := strings.TrimSuffix(string(p), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
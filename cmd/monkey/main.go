@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lsp"
+	"github.com/ebiiim/monkey/repl"
+	"github.com/ebiiim/monkey/script"
+)
+
+const usage = `Usage:
+	monkey [flags]                       start the REPL, or run a program from stdin if it is not a terminal
+	monkey [flags] run FILE [ARGS...]    run FILE ("-" reads stdin)
+	monkey [flags] -e EXPR [ARGS...]     run EXPR and print its value
+	monkey fmt [-w] [-d] [PATHS...]      format source files (see "monkey fmt -h")
+	monkey [flags] debug FILE [ARGS...]  run FILE in the debugger (eval engine only)
+	monkey lsp                           start a language server on stdin and stdout
+
+Modules imported with a path not starting with "./" or "../" are searched in
+the directories listed in the MONKEYPATH environment variable.
+
+Flags:
+`
+
+// Exit codes.
+const (
+	exitOK    = 0
+	exitError = 1 // parse or runtime error
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	fs := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	engine := fs.String("engine", repl.EngineEval, fmt.Sprintf("engine to run programs (%s or %s; %s has no %s)", repl.EngineEval, repl.EngineVM, repl.EngineVM, repl.VMLimits))
+	checked := fs.Bool("checked", false, "report integer overflow as an error (eval engine only)")
+	noPrelude := fs.Bool("no-prelude", false, "start without the prelude (eval engine only)")
+	expr := fs.String("e", "", "program text to run")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return exitUsage
+	}
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(os.Stderr, "unknown engine %q\n", *engine)
+		return exitUsage
+	}
+	cfg := repl.Config{
+		Engine:            *engine,
+		CheckedArithmetic: *checked,
+		ModulePath:        evaluator.ModulePathFromEnv(),
+		NoPrelude:         *noPrelude,
+	}
+
+	isSet := false
+	fs.Visit(func(f *flag.Flag) { isSet = isSet || f.Name == "e" })
+	if isSet {
+		return runSource("-e", *expr, fs.Args(), cfg, true)
+	}
+
+	args := fs.Args()
+	if len(args) > 0 && args[0] == "fmt" {
+		return runFmt(args[1:])
+	}
+	if len(args) > 0 && args[0] == "lsp" {
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitError
+		}
+		return exitOK
+	}
+	if len(args) > 0 && args[0] == "debug" {
+		if len(args) < 2 || cfg.Engine != repl.EngineEval {
+			fs.Usage()
+			return exitUsage
+		}
+		return runDebug(args[1], args[2:], cfg)
+	}
+	if len(args) == 0 {
+		if isTerminal(os.Stdin) {
+			repl.StartWithConfig(os.Stdin, os.Stdout, cfg)
+			return exitOK
+		}
+		return runFile("-", nil, cfg)
+	}
+	if args[0] != "run" || len(args) < 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	return runFile(args[1], args[2:], cfg)
+}
+
+func runFile(name string, args []string, cfg repl.Config) int {
+	var (
+		p   []byte
+		err error
+	)
+	if name == "-" {
+		p, err = ioutil.ReadAll(os.Stdin)
+		name = "<stdin>"
+	} else {
+		p, err = ioutil.ReadFile(name)
+		cfg.Dir, cfg.File = filepath.Dir(name), filepath.Base(name)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitError
+	}
+	return runSource(name, string(p), args, cfg, false)
+}
+
+func runSource(name, src string, args []string, cfg repl.Config, printResult bool) int {
+	result, err := script.Run(src, args, cfg)
+	if err != nil {
+		script.PrintError(os.Stderr, name, src, err)
+		return exitError
+	}
+	if printResult && result != nil && result != evaluator.NULL {
+		fmt.Println(result.Inspect())
+	}
+	return exitOK
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
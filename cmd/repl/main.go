@@ -1,19 +1,34 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/user"
 
+	"github.com/ebiiim/monkey/evaluator"
 	"github.com/ebiiim/monkey/repl"
 )
 
 func main() {
+	engine := flag.String("engine", repl.EngineEval, fmt.Sprintf("engine to run programs (%s or %s; %s has no %s)", repl.EngineEval, repl.EngineVM, repl.EngineVM, repl.VMLimits))
+	checked := flag.Bool("checked", false, "report integer overflow as an error (eval engine only)")
+	noPrelude := flag.Bool("no-prelude", false, "start without the prelude (eval engine only)")
+	flag.Parse()
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		log.Fatalf("[ERROR] unknown engine %q\n", *engine)
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		log.Fatalf("[ERROR] %v\n", err)
 	}
 	fmt.Printf("Hello %s! This is the Monkey programming language!\nFeel free to type in commands\n", user.Username)
-	repl.Start(os.Stdin, os.Stdout)
+	repl.StartWithConfig(os.Stdin, os.Stdout, repl.Config{
+		Engine:            *engine,
+		CheckedArithmetic: *checked,
+		ModulePath:        evaluator.ModulePathFromEnv(),
+		NoPrelude:         *noPrelude,
+	})
 }
@@ -0,0 +1,81 @@
+package code_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ebiiim/monkey/code"
+)
+
+func TestMake(t *testing.T) {
+	cases := []struct {
+		op       code.Opcode
+		operands []int
+		want     []byte
+	}{
+		{code.OpConstant, []int{65534}, []byte{byte(code.OpConstant), 255, 254}},
+		{code.OpAdd, []int{}, []byte{byte(code.OpAdd)}},
+		{code.OpGetLocal, []int{255}, []byte{byte(code.OpGetLocal), 255}},
+		{code.OpClosure, []int{65534, 255}, []byte{byte(code.OpClosure), 255, 254, 255}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(string(c.want), func(t *testing.T) {
+			got := code.Make(c.op, c.operands...)
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("want=%v got=%v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	ins := []code.Instructions{
+		code.Make(code.OpAdd),
+		code.Make(code.OpGetLocal, 1),
+		code.Make(code.OpConstant, 2),
+		code.Make(code.OpConstant, 65535),
+		code.Make(code.OpClosure, 65535, 255),
+	}
+	want := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+	var concatted code.Instructions
+	for _, in := range ins {
+		concatted = append(concatted, in...)
+	}
+	if concatted.String() != want {
+		t.Errorf("wrong instructions\nwant=%q\ngot=%q", want, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	cases := []struct {
+		op        code.Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{code.OpConstant, []int{65535}, 2},
+		{code.OpGetLocal, []int{255}, 1},
+		{code.OpClosure, []int{65535, 255}, 3},
+	}
+	for _, c := range cases {
+		ins := code.Make(c.op, c.operands...)
+		def, err := code.Lookup(byte(c.op))
+		if err != nil {
+			t.Fatalf("definition not found: %v", err)
+		}
+		got, n := code.ReadOperands(def, ins[1:])
+		if n != c.bytesRead {
+			t.Fatalf("n want=%d got=%d", c.bytesRead, n)
+		}
+		for i, want := range c.operands {
+			if got[i] != want {
+				t.Errorf("operand#%d want=%d got=%d", i, want, got[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,440 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/code"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/token"
+)
+
+// Errors
+var (
+	ErrUnsupportedNode     = errors.New("node not supported by compiler")
+	ErrUnsupportedOperator = errors.New("operator not supported by compiler")
+)
+
+// Bytecode contains compiled instructions and the constant pool.
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+}
+
+type emittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type compilationScope struct {
+	instructions        code.Instructions
+	lastInstruction     emittedInstruction
+	previousInstruction emittedInstruction
+}
+
+// Compiler compiles an AST into Bytecode.
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+	scopes      []compilationScope
+	scopeIndex  int
+}
+
+// New initializes a Compiler.
+func New() *Compiler {
+	return NewWithState(NewSymbolTable(), []object.Object{})
+}
+
+// NewWithState initializes a Compiler that keeps globals and constants of a previous compilation (e.g. in a REPL).
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []compilationScope{{}},
+	}
+}
+
+// Bytecode returns the compiled Bytecode.
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+	}
+}
+
+// Compile compiles the node recursively.
+func (c *Compiler) Compile(node ast.Node) error {
+	switch node := node.(type) {
+	// statements
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.LetStatement:
+		// functions may refer to themselves so define them first
+		var symbol Symbol
+		_, isFn := node.Value.(*ast.FunctionLiteral)
+		if isFn {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if !isFn {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		if symbol.Scope == GlobalScope {
+			c.emit(code.OpSetGlobal, symbol.Index)
+		} else {
+			c.emit(code.OpSetLocal, symbol.Index)
+		}
+	// expressions
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if ok {
+			c.loadSymbol(symbol)
+			return nil
+		}
+		if builtin, ok := evaluator.LookupBuiltin(node.Value); ok {
+			c.emit(code.OpConstant, c.addConstant(builtin))
+			return nil
+		}
+		if isPreludeName(node.Value) {
+			return fmt.Errorf("%w: %s (the prelude is only available in the evaluator)", evaluator.ErrIdentifierNotFound, node.Value)
+		}
+		return fmt.Errorf("%w: %s", evaluator.ErrIdentifierNotFound, node.Value)
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case token.BANG:
+			c.emit(code.OpBang)
+		case token.MINUS:
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("%w: %s", ErrUnsupportedOperator, node.Operator)
+		}
+	case *ast.InfixExpression:
+		if node.Operator == token.AND || node.Operator == token.OR {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrUnsupportedOperator, node.Operator)
+		}
+		c.emit(op)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, arg := range node.Arguments {
+			if err := c.Compile(arg); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.ArrayLiteral:
+		for _, elem := range node.Elements {
+			if err := c.Compile(elem); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	default:
+		if feature, ok := unsupportedFeatures[fmt.Sprintf("%T", node)]; ok {
+			return fmt.Errorf("%w: %s are only supported by the evaluator", ErrUnsupportedNode, feature)
+		}
+		return fmt.Errorf("%w: %T", ErrUnsupportedNode, node)
+	}
+	return nil
+}
+
+// unsupportedFeatures names the language features of the nodes the compiler rejects.
+var unsupportedFeatures = map[string]string{
+	"*ast.FloatLiteral":      "floats",
+	"*ast.AssignExpression":  "assignments",
+	"*ast.WhileStatement":    "loops",
+	"*ast.ForStatement":      "loops",
+	"*ast.BreakStatement":    "loops",
+	"*ast.ContinueStatement": "loops",
+	"*ast.TryStatement":      "try statements",
+	"*ast.ThrowStatement":    "throw statements",
+	"*ast.ImportExpression":  "modules",
+	"*ast.ExportStatement":   "modules",
+	"*ast.MemberExpression":  "modules",
+	"*ast.MacroLiteral":      "macros",
+}
+
+// isPreludeName reports whether name is defined by the prelude, which the compiler does not have.
+func isPreludeName(name string) bool {
+	for _, stmt := range evaluator.Prelude().Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok && let.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	token.PLUS:     code.OpAdd,
+	token.MINUS:    code.OpSub,
+	token.ASTERISK: code.OpMul,
+	token.SLASH:    code.OpDiv,
+	token.PERCENT:  code.OpMod,
+	token.EQ:       code.OpEqual,
+	token.NEQ:      code.OpNotEqual,
+	token.GT:       code.OpGreaterThan,
+	token.LT:       code.OpLessThan,
+	token.GTE:      code.OpGreaterThanOrEqual,
+	token.LTE:      code.OpLessThanOrEqual,
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	// emit with a bogus offset and fix it later
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileLogicalExpression compiles && and || so that the right operand is evaluated only when needed.
+// The result is always a boolean.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if node.Operator == token.AND {
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		jumpPos := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpPos, len(c.currentInstructions()))
+		return nil
+	}
+	c.emit(code.OpTrue)
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if err := c.compileTruthiness(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileTruthiness compiles node and converts its value to a boolean.
+func (c *Compiler) compileTruthiness(node ast.Node) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+	c.emit(code.OpBang)
+	c.emit(code.OpBang)
+	return nil
+}
+
+// compileBlockValue compiles the block so that it leaves its value on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if len(c.currentInstructions()) > start && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	if len(node.Defaults) != 0 || node.Rest != nil {
+		return fmt.Errorf("%w: default and rest parameters are only supported by the evaluator", ErrUnsupportedNode)
+	}
+	c.enterScope()
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+	if err := c.Compile(node.Body); err != nil {
+		c.leaveScope()
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions := c.leaveScope()
+	for _, s := range freeSymbols {
+		c.loadSymbol(s)
+	}
+	fn := &object.CompiledFunction{
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+	}
+	c.emit(code.OpClosure, c.addConstant(fn), len(freeSymbols))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	pos := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	return pos
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	c.scopes[c.scopeIndex].previousInstruction = c.scopes[c.scopeIndex].lastInstruction
+	c.scopes[c.scopeIndex].lastInstruction = emittedInstruction{Opcode: op, Position: pos}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = c.scopes[c.scopeIndex].previousInstruction
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	copy(ins[pos:], newInstruction)
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.replaceInstruction(opPos, code.Make(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, compilationScope{})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	ins := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return ins
+}
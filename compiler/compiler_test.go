@@ -0,0 +1,241 @@
+package compiler_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebiiim/monkey/code"
+	"github.com/ebiiim/monkey/compiler"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestCompile(t *testing.T) {
+	cases := []struct {
+		input         string
+		wantConstants []interface{}
+		wantIns       []code.Instructions
+	}{
+		{"1 + 2", []interface{}{1, 2}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpAdd),
+			code.Make(code.OpPop),
+		}},
+		{"1 < 2", []interface{}{1, 2}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpLessThan),
+			code.Make(code.OpPop),
+		}},
+		{"1 >= 2 && 3 % 2", []interface{}{1, 2, 3, 2}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpGreaterThanOrEqual),
+			code.Make(code.OpJumpNotTruthy, 22),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpConstant, 3),
+			code.Make(code.OpMod),
+			code.Make(code.OpBang),
+			code.Make(code.OpBang),
+			code.Make(code.OpJump, 23),
+			code.Make(code.OpFalse),
+			code.Make(code.OpPop),
+		}},
+		{"true || false", []interface{}{}, []code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpJumpNotTruthy, 8),
+			code.Make(code.OpTrue),
+			code.Make(code.OpJump, 11),
+			code.Make(code.OpFalse),
+			code.Make(code.OpBang),
+			code.Make(code.OpBang),
+			code.Make(code.OpPop),
+		}},
+		{"!true; -1", []interface{}{1}, []code.Instructions{
+			code.Make(code.OpTrue),
+			code.Make(code.OpBang),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpMinus),
+			code.Make(code.OpPop),
+		}},
+		{"if (true) { 10 }; 3333;", []interface{}{10, 3333}, []code.Instructions{
+			code.Make(code.OpTrue),              // 0000
+			code.Make(code.OpJumpNotTruthy, 10), // 0001
+			code.Make(code.OpConstant, 0),       // 0004
+			code.Make(code.OpJump, 11),          // 0007
+			code.Make(code.OpNull),              // 0010
+			code.Make(code.OpPop),               // 0011
+			code.Make(code.OpConstant, 1),       // 0012
+			code.Make(code.OpPop),               // 0015
+		}},
+		{"if (true) { let a = 1; }", []interface{}{1}, []code.Instructions{
+			code.Make(code.OpTrue),              // 0000
+			code.Make(code.OpJumpNotTruthy, 14), // 0001
+			code.Make(code.OpConstant, 0),       // 0004
+			code.Make(code.OpSetGlobal, 0),      // 0007
+			code.Make(code.OpNull),              // 0010
+			code.Make(code.OpJump, 15),          // 0011
+			code.Make(code.OpNull),              // 0014
+			code.Make(code.OpPop),               // 0015
+		}},
+		{"let one = 1; let two = one; two;", []interface{}{1}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpGetGlobal, 0),
+			code.Make(code.OpSetGlobal, 1),
+			code.Make(code.OpGetGlobal, 1),
+			code.Make(code.OpPop),
+		}},
+		{`[1, 2]; {"a": 1}["a"]`, []interface{}{1, 2, "a", 1, "a"}, []code.Instructions{
+			code.Make(code.OpConstant, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpArray, 2),
+			code.Make(code.OpPop),
+			code.Make(code.OpConstant, 2),
+			code.Make(code.OpConstant, 3),
+			code.Make(code.OpHash, 2),
+			code.Make(code.OpConstant, 4),
+			code.Make(code.OpIndex),
+			code.Make(code.OpPop),
+		}},
+		{"fn(a) { a }(1)", []interface{}{
+			[]code.Instructions{
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpReturnValue),
+			}, 1}, []code.Instructions{
+			code.Make(code.OpClosure, 0, 0),
+			code.Make(code.OpConstant, 1),
+			code.Make(code.OpCall, 1),
+			code.Make(code.OpPop),
+		}},
+		{"fn(a) { fn(b) { a + b } }", []interface{}{
+			[]code.Instructions{
+				code.Make(code.OpGetFree, 0),
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpAdd),
+				code.Make(code.OpReturnValue),
+			},
+			[]code.Instructions{
+				code.Make(code.OpGetLocal, 0),
+				code.Make(code.OpClosure, 0, 1),
+				code.Make(code.OpReturnValue),
+			}}, []code.Instructions{
+			code.Make(code.OpClosure, 1, 0),
+			code.Make(code.OpPop),
+		}},
+		{"let f = fn() { f() }; fn() {}", []interface{}{
+			[]code.Instructions{
+				code.Make(code.OpCurrentClosure),
+				code.Make(code.OpCall, 0),
+				code.Make(code.OpReturnValue),
+			},
+			[]code.Instructions{
+				code.Make(code.OpReturn),
+			}}, []code.Instructions{
+			code.Make(code.OpClosure, 0, 0),
+			code.Make(code.OpSetGlobal, 0),
+			code.Make(code.OpClosure, 1, 0),
+			code.Make(code.OpPop),
+		}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				t.Fatalf("compile error: %v", err)
+			}
+			bc := comp.Bytecode()
+			testInstructions(t, c.wantIns, bc.Instructions)
+			testConstants(t, c.wantConstants, bc.Constants)
+		})
+	}
+}
+
+func TestCompileBuiltins(t *testing.T) {
+	comp := compiler.New()
+	if err := comp.Compile(parser.New(lexer.New(`len([])`)).ParseProgram()); err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+	bc := comp.Bytecode()
+	want, _ := evaluator.LookupBuiltin("len")
+	if len(bc.Constants) != 1 || bc.Constants[0] != want {
+		t.Errorf("wrong constants %+v", bc.Constants)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    error
+		wantMsg string
+	}{
+		{"foobar", evaluator.ErrIdentifierNotFound, "identifier not found: foobar"},
+		{"fn() { foobar }", evaluator.ErrIdentifierNotFound, "identifier not found: foobar"},
+		{"map([1], fn(x) { x })", evaluator.ErrIdentifierNotFound, "identifier not found: map (the prelude is only available in the evaluator)"},
+		{"fn(a = 1) { a }", compiler.ErrUnsupportedNode, "node not supported by compiler: default and rest parameters are only supported by the evaluator"},
+		{"fn(...a) { a }", compiler.ErrUnsupportedNode, "node not supported by compiler: default and rest parameters are only supported by the evaluator"},
+		{"let a = 1; a = 2", compiler.ErrUnsupportedNode, "node not supported by compiler: assignments are only supported by the evaluator"},
+		{"for (x in [1]) { x }", compiler.ErrUnsupportedNode, "node not supported by compiler: loops are only supported by the evaluator"},
+		{"while (true) { }", compiler.ErrUnsupportedNode, "node not supported by compiler: loops are only supported by the evaluator"},
+		{"1.5", compiler.ErrUnsupportedNode, "node not supported by compiler: floats are only supported by the evaluator"},
+		{"try { 1 } catch (e) { 2 }", compiler.ErrUnsupportedNode, "node not supported by compiler: try statements are only supported by the evaluator"},
+		{`import("./m")`, compiler.ErrUnsupportedNode, "node not supported by compiler: modules are only supported by the evaluator"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			err := compiler.New().Compile(parser.New(lexer.New(c.input)).ParseProgram())
+			if !errors.Is(err, c.want) {
+				t.Errorf("want=%v got=%v", c.want, err)
+			}
+			if err != nil && err.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func testInstructions(t *testing.T, want []code.Instructions, got code.Instructions) {
+	t.Helper()
+	var concatted code.Instructions
+	for _, ins := range want {
+		concatted = append(concatted, ins...)
+	}
+	if concatted.String() != got.String() {
+		t.Fatalf("wrong instructions\nwant=\n%s\ngot=\n%s", concatted, got)
+	}
+}
+
+func testConstants(t *testing.T, want []interface{}, got []object.Object) {
+	t.Helper()
+	if len(want) != len(got) {
+		t.Fatalf("wrong number of constants want=%d got=%d", len(want), len(got))
+	}
+	for i, w := range want {
+		switch w := w.(type) {
+		case int:
+			o, ok := got[i].(*object.Integer)
+			if !ok || o.Value != int64(w) {
+				t.Errorf("constant#%d want=%d got=%+v", i, w, got[i])
+			}
+		case string:
+			o, ok := got[i].(*object.String)
+			if !ok || o.Value != w {
+				t.Errorf("constant#%d want=%s got=%+v", i, w, got[i])
+			}
+		case []code.Instructions:
+			fn, ok := got[i].(*object.CompiledFunction)
+			if !ok {
+				t.Fatalf("constant#%d is not CompiledFunction but %T", i, got[i])
+			}
+			testInstructions(t, w, fn.Instructions)
+		}
+	}
+}
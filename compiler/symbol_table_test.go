@@ -0,0 +1,60 @@
+package compiler_test
+
+import (
+	"testing"
+
+	"github.com/ebiiim/monkey/compiler"
+)
+
+func TestDefineAndResolve(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	a := global.Define("a")
+	local := compiler.NewEnclosedSymbolTable(global)
+	b := local.Define("b")
+	nested := compiler.NewEnclosedSymbolTable(local)
+	c := nested.Define("c")
+
+	cases := []struct {
+		table *compiler.SymbolTable
+		name  string
+		want  compiler.Symbol
+	}{
+		{global, "a", compiler.Symbol{Name: "a", Scope: compiler.GlobalScope, Index: 0}},
+		{local, "a", compiler.Symbol{Name: "a", Scope: compiler.GlobalScope, Index: 0}},
+		{local, "b", compiler.Symbol{Name: "b", Scope: compiler.LocalScope, Index: 0}},
+		{nested, "a", compiler.Symbol{Name: "a", Scope: compiler.GlobalScope, Index: 0}},
+		{nested, "b", compiler.Symbol{Name: "b", Scope: compiler.FreeScope, Index: 0}},
+		{nested, "c", compiler.Symbol{Name: "c", Scope: compiler.LocalScope, Index: 0}},
+	}
+	if a != cases[0].want || b != cases[2].want || c != cases[5].want {
+		t.Fatalf("wrong definitions a=%+v b=%+v c=%+v", a, b, c)
+	}
+	for _, tc := range cases {
+		got, ok := tc.table.Resolve(tc.name)
+		if !ok {
+			t.Errorf("%s not resolvable", tc.name)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s want=%+v got=%+v", tc.name, tc.want, got)
+		}
+	}
+	if len(nested.FreeSymbols) != 1 || nested.FreeSymbols[0] != b {
+		t.Errorf("wrong nested.FreeSymbols %+v", nested.FreeSymbols)
+	}
+	if _, ok := global.Resolve("b"); ok {
+		t.Errorf("b resolved in global")
+	}
+}
+
+func TestDefineFunctionName(t *testing.T) {
+	global := compiler.NewSymbolTable()
+	global.Define("a")
+	local := compiler.NewEnclosedSymbolTable(global)
+	local.DefineFunctionName("a")
+	got, ok := local.Resolve("a")
+	want := compiler.Symbol{Name: "a", Scope: compiler.FunctionScope, Index: 0}
+	if !ok || got != want {
+		t.Errorf("want=%+v got=%+v", want, got)
+	}
+}
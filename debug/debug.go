@@ -0,0 +1,354 @@
+// Package debug runs a Monkey program under a line-oriented debugger
+// that stops at breakpoints and steps through the statements of the program.
+package debug
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/script"
+)
+
+// PROMPT is the prompt text shown while the program is stopped.
+const PROMPT = "(debug) "
+
+// modes decide where the program stops next besides breakpoints.
+type mode int
+
+const (
+	modeStep     mode = iota // at the next statement
+	modeNext                 // at the next statement not in a call made from the current one
+	modeOut                  // at the next statement after the current call returns
+	modeContinue             // at breakpoints only
+)
+
+// Debugger runs a program and reads commands whenever it stops.
+type Debugger struct {
+	name, src string
+	in        *bufio.Scanner
+	out       io.Writer
+	opts      evaluator.Options
+
+	ev          *evaluator.Evaluator
+	cancel      context.CancelFunc
+	stmts       map[ast.Statement]bool   // statements of the program, not of the prelude or modules
+	rows        map[int]bool             // rows where statements of the program start
+	calls       map[[2]int]bool          // rows and columns of the calls in the program
+	prelude     map[string]object.Object // global bindings made before the program runs
+	breakpoints map[int]bool
+	mode        mode
+	depth       int // call depth where the last step started
+	quit        bool
+}
+
+// New initializes a Debugger for the program src read from the file name.
+// Commands are read from in and the debugger writes to out. The program runs with opts except Hook.
+func New(name, src string, in io.Reader, out io.Writer, opts evaluator.Options) *Debugger {
+	return &Debugger{
+		name:        name,
+		src:         src,
+		in:          bufio.NewScanner(in),
+		out:         out,
+		opts:        opts,
+		breakpoints: make(map[int]bool),
+	}
+}
+
+// Run runs the program with args as script.ArgsName and stops before its first statement.
+// It returns a parser.ErrorList or an *object.Error on failure, and a nil error if the user quits.
+func (d *Debugger) Run(args []string) (object.Object, error) {
+	p := parser.New(lexer.New(script.StripShebang(d.src)))
+	program := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	ctx := d.opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, d.cancel = context.WithCancel(ctx)
+	defer d.cancel()
+	opts := d.opts
+	opts.Context = ctx
+	opts.Hook = d
+	d.ev = evaluator.New(opts)
+
+	env := d.ev.NewEnvironment()
+	d.prelude = make(map[string]object.Object)
+	for _, name := range env.Names() {
+		d.prelude[name], _ = env.Get(name)
+	}
+	elems := make([]object.Object, len(args))
+	for i, arg := range args {
+		elems[i] = &object.String{Value: arg}
+	}
+	env.Set(script.ArgsName, &object.Array{Elements: elems})
+
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	expanded, err := d.ev.ExpandMacros(program, macros)
+	if err != nil {
+		return nil, err
+	}
+	d.stmts = make(map[ast.Statement]bool)
+	d.rows = make(map[int]bool)
+	d.calls = make(map[[2]int]bool)
+	ast.Modify(expanded, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case ast.Statement:
+			d.stmts[node] = true
+			row, _ := node.Pos()
+			d.rows[row] = true
+		case *ast.CallExpression:
+			row, col := node.Function.Pos()
+			d.calls[[2]int{row, col}] = true
+		}
+		return node
+	})
+
+	d.mode = modeStep
+	result := d.ev.Eval(expanded, env)
+	if errObj, ok := result.(*object.Error); ok {
+		if d.quit {
+			return nil, nil
+		}
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// BeforeStatement implements evaluator.Hook.
+func (d *Debugger) BeforeStatement(stmt ast.Statement, row, col int, env *object.Environment) {
+	if d.quit || !d.stmts[stmt] {
+		return
+	}
+	depth := len(d.ev.Stack())
+	stop := d.breakpoints[row]
+	switch d.mode {
+	case modeStep:
+		stop = true
+	case modeNext:
+		stop = stop || depth <= d.depth
+	case modeOut:
+		stop = stop || depth < d.depth
+	}
+	if stop {
+		d.stop(row, col, env)
+	}
+}
+
+// stop reads commands until one resumes the program.
+func (d *Debugger) stop(row, col int, env *object.Environment) {
+	fmt.Fprintf(d.out, "%s:%d:%d in %s\n", d.name, row, col, d.function(len(d.ev.Stack())-1))
+	d.printLine(row)
+	for {
+		fmt.Fprint(d.out, PROMPT)
+		if !d.in.Scan() {
+			d.exit()
+			return
+		}
+		line := strings.TrimSpace(d.in.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		arg := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		switch fields[0] {
+		case "s", "step":
+			d.resume(modeStep)
+			return
+		case "n", "next":
+			d.resume(modeNext)
+			return
+		case "o", "out":
+			d.resume(modeOut)
+			return
+		case "c", "continue":
+			d.resume(modeContinue)
+			return
+		case "q", "quit":
+			d.exit()
+			return
+		case "b", "break":
+			d.setBreakpoint(arg)
+		case "d", "delete":
+			d.deleteBreakpoint(arg)
+		case "p", "print":
+			d.print(arg, env)
+		case "locals":
+			d.printLocals(env)
+		case "globals":
+			d.printGlobals(env)
+		case "bt", "backtrace":
+			d.printBacktrace(row, col)
+		default:
+			help(d.out)
+		}
+	}
+}
+
+func help(out io.Writer) {
+	fmt.Fprint(out, `Debugger Commands:
+	[ step | s ] Run to the next statement, entering calls.
+	[ next | n ] Run to the next statement, stepping over calls.
+	[ out | o ] Run until the current call returns.
+	[ continue | c ] Run to the next breakpoint.
+	[ break LINE | b LINE ] Set a breakpoint, or list them without LINE.
+	[ delete LINE | d LINE ] Delete a breakpoint, or all of them without LINE.
+	[ print EXPR | p EXPR ] Evaluate EXPR in the current scope.
+	[ locals ] Print the variables of the current call by scope.
+	[ globals ] Print the global variables of the program.
+	[ backtrace | bt ] Print the active calls.
+	[ quit | q ] Stop the program.
+`)
+}
+
+func (d *Debugger) resume(m mode) {
+	d.mode = m
+	d.depth = len(d.ev.Stack())
+}
+
+// exit stops the program with evaluator.ErrEvaluationCanceled.
+func (d *Debugger) exit() {
+	d.quit = true
+	d.cancel()
+}
+
+// function returns the name of the function called at d.ev.Stack()[i], or <main> if i < 0.
+func (d *Debugger) function(i int) string {
+	if i < 0 {
+		return "<main>"
+	}
+	if name := d.ev.Stack()[i].Name; name != "" {
+		return name
+	}
+	return "<anonymous>"
+}
+
+func (d *Debugger) printLine(row int) {
+	lines := strings.Split(d.src, "\n")
+	if row < 1 || row > len(lines) {
+		return
+	}
+	fmt.Fprintf(d.out, " %d | %s\n", row, strings.TrimSuffix(lines[row-1], "\r"))
+}
+
+func (d *Debugger) setBreakpoint(arg string) {
+	if arg == "" {
+		rows := make([]int, 0, len(d.breakpoints))
+		for row := range d.breakpoints {
+			rows = append(rows, row)
+		}
+		sort.Ints(rows)
+		for _, row := range rows {
+			fmt.Fprintf(d.out, "breakpoint at %s:%d\n", d.name, row)
+		}
+		return
+	}
+	row, err := strconv.Atoi(arg)
+	switch {
+	case err != nil:
+		fmt.Fprintf(d.out, "invalid line %q\n", arg)
+	case !d.rows[row]:
+		fmt.Fprintf(d.out, "no statement starts at line %d\n", row)
+	default:
+		d.breakpoints[row] = true
+		fmt.Fprintf(d.out, "breakpoint at %s:%d\n", d.name, row)
+	}
+}
+
+func (d *Debugger) deleteBreakpoint(arg string) {
+	if arg == "" {
+		d.breakpoints = make(map[int]bool)
+		return
+	}
+	row, err := strconv.Atoi(arg)
+	if err != nil || !d.breakpoints[row] {
+		fmt.Fprintf(d.out, "no breakpoint at line %q\n", arg)
+		return
+	}
+	delete(d.breakpoints, row)
+}
+
+// print evaluates expr in env without the debugger so that calls in it do not stop.
+func (d *Debugger) print(expr string, env *object.Environment) {
+	p := parser.New(lexer.New(expr))
+	program := p.ParseProgram()
+	for _, err := range p.Errors() {
+		fmt.Fprintf(d.out, "\t%s\n", err)
+	}
+	if len(p.Errors()) != 0 {
+		return
+	}
+	opts := d.opts
+	opts.Hook = nil
+	if result := evaluator.New(opts).Eval(program, env); result != nil {
+		fmt.Fprintln(d.out, describe(result))
+	}
+}
+
+// printLocals prints the scopes from the innermost one up to but not including the global one.
+func (d *Debugger) printLocals(env *object.Environment) {
+	if env.Outer() == nil {
+		fmt.Fprintln(d.out, "no locals at the top level")
+		return
+	}
+	for i, e := 0, env; e.Outer() != nil; i, e = i+1, e.Outer() {
+		fmt.Fprintf(d.out, "scope %d:\n", i)
+		for _, name := range e.Names() {
+			val, _ := e.Get(name)
+			fmt.Fprintf(d.out, "\t%s = %s\n", name, describe(val))
+		}
+	}
+}
+
+// printGlobals prints the global bindings except the ones of the prelude that are not redefined.
+func (d *Debugger) printGlobals(env *object.Environment) {
+	for env.Outer() != nil {
+		env = env.Outer()
+	}
+	for _, name := range env.Names() {
+		val, _ := env.Get(name)
+		if d.prelude[name] != val {
+			fmt.Fprintf(d.out, "\t%s = %s\n", name, describe(val))
+		}
+	}
+}
+
+// printBacktrace prints the current position and then the call sites, innermost first.
+// Calls made by functions of the prelude or modules are not located because their rows are in other sources.
+func (d *Debugger) printBacktrace(row, col int) {
+	stack := d.ev.Stack()
+	fmt.Fprintf(d.out, "#0 %s at %s:%d:%d\n", d.function(len(stack)-1), d.name, row, col)
+	for i := len(stack) - 1; i >= 0; i-- {
+		f := stack[i]
+		if d.calls[[2]int{f.Row, f.Col}] {
+			fmt.Fprintf(d.out, "#%d %s at %s:%d:%d\n", len(stack)-i, d.function(i-1), d.name, f.Row, f.Col)
+		} else {
+			fmt.Fprintf(d.out, "#%d %s in another source\n", len(stack)-i, d.function(i-1))
+		}
+	}
+}
+
+// describe returns obj as the REPL prints it, but only the signature of functions.
+func describe(obj object.Object) string {
+	if fn, ok := obj.(*object.Function); ok {
+		name := fn.Name
+		if name != "" {
+			name = " " + name
+		}
+		return fmt.Sprintf("fn%s(%s)", name, ast.FormatParameters(fn.Parameters, fn.Defaults, fn.Rest))
+	}
+	return obj.Inspect()
+}
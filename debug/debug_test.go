@@ -0,0 +1,160 @@
+package debug_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ebiiim/monkey/debug"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/parser"
+)
+
+const program = `let add = fn(a, b) {
+  let s = a + b;
+  s
+};
+let twice = fn(x) {
+  let y = add(x, x);
+  y * 2
+};
+let r = twice(3);
+let m = map([1], fn(v) { v + r });
+r`
+
+func TestDebugger(t *testing.T) {
+	cases := []struct {
+		name     string
+		commands string
+		want     string
+	}{
+		{"step", "s\ns\ns\ns\ns\nc\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) x.monkey:5:1 in <main>
+ 5 | let twice = fn(x) {
+(debug) x.monkey:9:1 in <main>
+ 9 | let r = twice(3);
+(debug) x.monkey:6:3 in twice
+ 6 |   let y = add(x, x);
+(debug) x.monkey:2:3 in add
+ 2 |   let s = a + b;
+(debug) x.monkey:3:3 in add
+ 3 |   s
+(debug) `},
+		{"next", "n\nn\nn\nn\nn\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) x.monkey:5:1 in <main>
+ 5 | let twice = fn(x) {
+(debug) x.monkey:9:1 in <main>
+ 9 | let r = twice(3);
+(debug) x.monkey:10:1 in <main>
+ 10 | let m = map([1], fn(v) { v + r });
+(debug) x.monkey:11:1 in <main>
+ 11 | r
+(debug) `},
+		{"out", "b 2\nc\no\no\nc\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) breakpoint at x.monkey:2
+(debug) x.monkey:2:3 in add
+ 2 |   let s = a + b;
+(debug) x.monkey:7:3 in twice
+ 7 |   y * 2
+(debug) x.monkey:10:1 in <main>
+ 10 | let m = map([1], fn(v) { v + r });
+(debug) `},
+		{"breakpoints", "b 4\nb x\nb 7\nb 3\nb\nd 3\nd 3\nc\nd\nc\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) no statement starts at line 4
+(debug) invalid line "x"
+(debug) breakpoint at x.monkey:7
+(debug) breakpoint at x.monkey:3
+(debug) breakpoint at x.monkey:3
+breakpoint at x.monkey:7
+(debug) (debug) no breakpoint at line "3"
+(debug) x.monkey:7:3 in twice
+ 7 |   y * 2
+(debug) (debug) `},
+		{"inspect", "b 3\nc\nlocals\nglobals\np a * 10\np b +\np s\nbt\nq\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) breakpoint at x.monkey:3
+(debug) x.monkey:3:3 in add
+ 3 |   s
+(debug) scope 0:
+	a = 3
+	b = 3
+	s = 6
+(debug) 	add = fn add(a, b)
+	args = [a, ]
+	twice = fn twice(x)
+(debug) 30
+(debug) 	1:4 no prefix parse function for EOF found (ErrNoParseFunc)
+(debug) 6
+(debug) #0 add at x.monkey:3:3
+#1 twice at x.monkey:6:11
+#2 <main> at x.monkey:9:9
+(debug) `},
+		{"closure", "b 10\nc\ns\nlocals\nbt\nq\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) breakpoint at x.monkey:10
+(debug) x.monkey:10:1 in <main>
+ 10 | let m = map([1], fn(v) { v + r });
+(debug) x.monkey:10:26 in <anonymous>
+ 10 | let m = map([1], fn(v) { v + r });
+(debug) scope 0:
+	v = 1
+(debug) #0 <anonymous> at x.monkey:10:26
+#1 map in another source
+#2 <main> at x.monkey:10:9
+(debug) `},
+		{"top level locals", "locals\nq\n", `x.monkey:1:1 in <main>
+ 1 | let add = fn(a, b) {
+(debug) no locals at the top level
+(debug) `},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			d := debug.New("x.monkey", program, strings.NewReader(c.commands), &out, evaluator.Options{})
+			if _, err := d.Run([]string{"a"}); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if out.String() != c.want {
+				t.Errorf("want=%q got=%q", c.want, out.String())
+			}
+		})
+	}
+}
+
+func TestDebuggerResult(t *testing.T) {
+	var out bytes.Buffer
+	result, err := debug.New("x.monkey", program, strings.NewReader("c\n"), &out, evaluator.Options{}).Run(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Inspect() != "12" {
+		t.Errorf("want=12 got=%s", result.Inspect())
+	}
+}
+
+func TestDebuggerErr(t *testing.T) {
+	cases := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"parse", "let = 1;", parser.ErrTokenType},
+		{"runtime", "1 + true", evaluator.ErrTypeMismatch},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var out bytes.Buffer
+			_, err := debug.New("x.monkey", c.input, strings.NewReader("c\n"), &out, evaluator.Options{}).Run(nil)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, err)
+			}
+		})
+	}
+}
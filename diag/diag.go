@@ -0,0 +1,175 @@
+// Package diag renders errors with the source line they point to and a marker under the offending span:
+//
+//	x.monkey:2:5 expected "IDENT" but got "=" instead (ErrTokenType)
+//	 2 | let = 1;
+//	   |     ^
+package diag
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/token"
+)
+
+// Diagnostic is an error to render. Rows and columns are 1-based as in tokens.
+type Diagnostic struct {
+	Header         string // the first line, usually the error with its position
+	Row, Col       int    // the start of the span; no snippet is rendered if Row is 0
+	EndRow, EndCol int    // the exclusive end of the span; the token at Row and Col if EndRow is 0
+	Label          string // printed after the marker
+	Hint           string // printed below the snippet
+}
+
+// ANSI escape sequences used with color.
+const (
+	bold  = "\x1b[1m"
+	red   = "\x1b[1;31m"
+	blue  = "\x1b[1;34m"
+	cyan  = "\x1b[36m"
+	reset = "\x1b[0m"
+)
+
+// Render writes d with its line in src. Color adds ANSI escape sequences for terminals.
+func Render(w io.Writer, src string, d Diagnostic, color bool) {
+	paint := func(style, s string) string {
+		if !color || s == "" {
+			return s
+		}
+		return style + s + reset
+	}
+	fmt.Fprintln(w, paint(bold, d.Header))
+	lines := strings.Split(src, "\n")
+	if d.Row < 1 || d.Row > len(lines) || d.Col < 1 {
+		if d.Hint != "" {
+			fmt.Fprintf(w, "%s %s\n", paint(blue, "="), paint(cyan, "hint: "+d.Hint))
+		}
+		return
+	}
+	// tabs take lexer.TabSize columns in token positions so expanding them aligns the columns
+	line := strings.ReplaceAll(strings.TrimSuffix(lines[d.Row-1], "\r"), "\t", strings.Repeat(" ", lexer.TabSize))
+	num := strconv.Itoa(d.Row)
+	gutter := strings.Repeat(" ", len(num))
+	marker := "^" + strings.Repeat("~", spanWidth(src, line, d)-1)
+	if d.Label != "" {
+		marker += " " + d.Label
+	}
+	fmt.Fprintf(w, "%s %s\n", paint(blue, " "+num+" |"), line)
+	fmt.Fprintf(w, "%s %s%s\n", paint(blue, " "+gutter+" |"), strings.Repeat(" ", d.Col-1), paint(red, marker))
+	if d.Hint != "" {
+		fmt.Fprintf(w, "%s %s\n", paint(blue, " "+gutter+" ="), paint(cyan, "hint: "+d.Hint))
+	}
+}
+
+// spanWidth returns the number of columns to mark in line, at least 1.
+func spanWidth(src, line string, d Diagnostic) int {
+	endRow, endCol := d.EndRow, d.EndCol
+	if endRow == 0 {
+		endRow, endCol = tokenEnd(src, d.Row, d.Col)
+	}
+	width := endCol - d.Col
+	if endRow > d.Row {
+		width = utf8.RuneCountInString(line) + 1 - d.Col
+	}
+	if width < 1 {
+		return 1
+	}
+	return width
+}
+
+// tokenEnd returns the end of the token at row and col, or the next column if there is none.
+func tokenEnd(src string, row, col int) (int, int) {
+	l := lexer.New(src)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		if tok.Row > row || (tok.Row == row && tok.Col > col) {
+			break
+		}
+		if tok.Row == row && tok.Col == col {
+			n := utf8.RuneCountInString(tok.Literal)
+			if tok.Type == token.STRING {
+				n += 2 // quotes
+			}
+			return row, col + n
+		}
+	}
+	return row, col + 1
+}
+
+// FromParser converts a parser diagnostic.
+func FromParser(d parser.Diagnostic) Diagnostic {
+	return Diagnostic{
+		Header: d.Err.Error(),
+		Row:    d.Start.Row,
+		Col:    d.Start.Col,
+		EndRow: d.End.Row,
+		EndCol: d.End.Col,
+		Hint:   Hint(d.Err),
+	}
+}
+
+// FromError converts a runtime error to render with the source named file as in object.Error.File.
+// The snippet marks the error if it is in the source. Otherwise, such as in a module or the prelude,
+// it marks the innermost call in the source that led to the error, or is omitted if there is none.
+func FromError(errObj *object.Error, file string) Diagnostic {
+	d := Diagnostic{Header: errObj.Error(), Row: errObj.Row, Col: errObj.Col, Hint: Hint(errObj)}
+	if errObj.File == file {
+		return d
+	}
+	d.Row, d.Col = 0, 0
+	for _, f := range errObj.Stack {
+		if f.File != file {
+			continue
+		}
+		name := f.Name
+		if name == "" {
+			name = "<anonymous>"
+		}
+		d.Row, d.Col, d.Label = f.Row, f.Col, "error in "+name
+		break
+	}
+	return d
+}
+
+var hints = []struct {
+	err  error
+	hint string
+}{
+	{lexer.ErrInvalidEscape, `write \\ for a backslash`},
+	{parser.ErrOutsideLoop, "break and continue can only be used in a while or for loop"},
+	{parser.ErrNotTopLevel, "move the export statement to the top level of the module"},
+	{parser.ErrInvalidAssign, "only identifiers and index expressions can be assigned to"},
+	{evaluator.ErrIdentifierNotFound, "define it with let before it is used"},
+	{evaluator.ErrUnusableAsHashKey, "use a string, an integer or a boolean as the key"},
+}
+
+// Hint returns a hint for err, or "" if there is none.
+func Hint(err error) string {
+	for _, h := range hints {
+		if errors.Is(err, h.err) {
+			return h.hint
+		}
+	}
+	return ""
+}
+
+// IsTerminal reports whether w is a terminal, where output may be colored.
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
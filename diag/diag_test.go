@@ -0,0 +1,104 @@
+package diag_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ebiiim/monkey/diag"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestRender(t *testing.T) {
+	cases := []struct {
+		name  string
+		src   string
+		d     diag.Diagnostic
+		color bool
+		want  string
+	}{
+		{"token", "let a = 1;\nlet b = a +* 2;", diag.Diagnostic{Header: "2:12 oops", Row: 2, Col: 12}, false,
+			"2:12 oops\n 2 | let b = a +* 2;\n   |            ^\n"},
+		{"span", "let abc = 1;", diag.Diagnostic{Header: "h", Row: 1, Col: 5, EndRow: 1, EndCol: 8, Label: "here"}, false,
+			"h\n 1 | let abc = 1;\n   |     ^~~ here\n"},
+		{"token span", `puts("hello")`, diag.Diagnostic{Header: "h", Row: 1, Col: 6}, false,
+			"h\n 1 | puts(\"hello\")\n   |      ^~~~~~~\n"},
+		{"multi-line span", "if (x) {\n}", diag.Diagnostic{Header: "h", Row: 1, Col: 8, EndRow: 2, EndCol: 2}, false,
+			"h\n 1 | if (x) {\n   |        ^\n"},
+		{"tab", "\tlet = 1;", diag.Diagnostic{Header: "h", Row: 1, Col: 9}, false,
+			"h\n 1 |     let = 1;\n   |         ^\n"},
+		{"hint", "foo", diag.Diagnostic{Header: "h", Row: 1, Col: 1, Hint: "try bar"}, false,
+			"h\n 1 | foo\n   | ^~~\n   = hint: try bar\n"},
+		{"no position", "foo", diag.Diagnostic{Header: "h", Hint: "try bar"}, false,
+			"h\n= hint: try bar\n"},
+		{"out of range", "foo", diag.Diagnostic{Header: "h", Row: 3, Col: 1}, false,
+			"h\n"},
+		{"wide gutter", "\n\n\n\n\n\n\n\n\nfoo", diag.Diagnostic{Header: "h", Row: 10, Col: 1}, false,
+			"h\n 10 | foo\n    | ^~~\n"},
+		{"color", "foo", diag.Diagnostic{Header: "h", Row: 1, Col: 1, Hint: "x"}, true,
+			"\x1b[1mh\x1b[0m\n\x1b[1;34m 1 |\x1b[0m foo\n\x1b[1;34m   |\x1b[0m \x1b[1;31m^~~\x1b[0m\n\x1b[1;34m   =\x1b[0m \x1b[36mhint: x\x1b[0m\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			diag.Render(&buf, c.src, c.d, c.color)
+			if buf.String() != c.want {
+				t.Errorf("want=%q got=%q", c.want, buf.String())
+			}
+		})
+	}
+}
+
+func TestFromParser(t *testing.T) {
+	src := "let x = 1;\nx = \"a\\q\";"
+	p := parser.New(lexer.New(src))
+	p.ParseProgram()
+	diags := p.Diagnostics()
+	if len(diags) == 0 {
+		t.Fatal("want diagnostics")
+	}
+	var buf bytes.Buffer
+	diag.Render(&buf, src, diag.FromParser(diags[0]), false)
+	want := diags[0].Err.Error() + "\n 2 | x = \"a\\q\";\n   |       ^~\n   = hint: write \\\\ for a backslash\n"
+	if buf.String() != want {
+		t.Errorf("want=%q got=%q", want, buf.String())
+	}
+}
+
+func TestFromError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  *object.Error
+		want diag.Diagnostic
+	}{
+		{"top level", &object.Error{Message: evaluator.ErrIdentifierNotFound, Row: 2, Col: 3},
+			diag.Diagnostic{Header: "2:3 identifier not found", Row: 2, Col: 3, Hint: "define it with let before it is used"}},
+		{"call", &object.Error{Message: evaluator.ErrTypeMismatch, Row: 1, Col: 20, Stack: []object.Frame{{Name: "g", Row: 1, Col: 30}, {Name: "f", Row: 4, Col: 1}}},
+			diag.Diagnostic{Header: "1:20 type mismatch", Row: 1, Col: 20}},
+		{"module", &object.Error{Message: evaluator.ErrTypeMismatch, File: "m.monkey", Row: 1, Col: 20, Stack: []object.Frame{{Name: "g", File: "m.monkey", Row: 1, Col: 30}, {Name: "f", Row: 4, Col: 1}}},
+			diag.Diagnostic{Header: "1:20 type mismatch", Row: 4, Col: 1, Label: "error in f"}},
+		{"prelude", &object.Error{Message: evaluator.ErrTypeMismatch, File: evaluator.PreludeFile, Row: 1, Col: 20, Stack: []object.Frame{{Row: 2, Col: 1}}},
+			diag.Diagnostic{Header: "1:20 type mismatch", Row: 2, Col: 1, Label: "error in <anonymous>"}},
+		{"not called from the source", &object.Error{Message: evaluator.ErrTypeMismatch, File: "m.monkey", Row: 1, Col: 20, Stack: []object.Frame{{Name: "g", File: "m.monkey", Row: 1, Col: 30}}},
+			diag.Diagnostic{Header: "1:20 type mismatch"}},
+		{"no position", &object.Error{Message: evaluator.ErrTypeMismatch},
+			diag.Diagnostic{Header: "type mismatch"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			if got := diag.FromError(c.err, ""); got != c.want {
+				t.Errorf("want=%+v got=%+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	if diag.IsTerminal(&bytes.Buffer{}) {
+		t.Error("a buffer is not a terminal")
+	}
+}
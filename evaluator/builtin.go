@@ -3,18 +3,49 @@ package evaluator
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/ebiiim/monkey/object"
 )
 
 var builtins = map[string]*object.Builtin{
-	"len":   {Fn: fnLen},
-	"first": {Fn: fnFirst},
-	"last":  {Fn: fnLast},
-	"rest":  {Fn: fnRest},
-	"push":  {Fn: fnPush},
-	"pop":   {Fn: fnPop},
-	"puts":  {Fn: fnPuts},
+	"len":    {Fn: fnLen},
+	"first":  {Fn: fnFirst},
+	"last":   {Fn: fnLast},
+	"rest":   {Fn: fnRest},
+	"push":   {Fn: fnPush},
+	"append": {Fn: fnAppend},
+	"pop":    {Fn: fnPop},
+	"puts":   {Fn: fnPuts},
+	"keys":   {Fn: fnKeys},
+	"values": {Fn: fnValues},
+	"put":    {Fn: fnPut},
+	"delete": {Fn: fnDelete},
+	"range":  {Fn: fnRange},
+	"int":    {Fn: fnInt},
+	"float":  {Fn: fnFloat},
+	"round":  {Fn: fnRound},
+	"floor":  {Fn: fnFloor},
+	"error":  {Fn: fnError},
+}
+
+// BuiltinNames returns the names of the builtin functions in sorted order.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupBuiltin finds a builtin function by name.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	fn, ok := builtins[name]
+	return fn, ok
 }
 
 // Builtin function errors.
@@ -23,7 +54,9 @@ var (
 	ErrTooFewArgs       = errors.New("too few arguments")
 	ErrTypeNotSupported = errors.New("type not supported")
 	ErrArrayNeeded      = errors.New("argument must be Array")
+	ErrHashNeeded       = errors.New("argument must be Hash")
 	ErrFileOpenFailed   = errors.New("failed to open file")
+	ErrInvalidArgument  = errors.New("invalid argument")
 )
 
 var fnLen = func(args ...object.Object) object.Object {
@@ -35,6 +68,10 @@ var fnLen = func(args ...object.Object) object.Object {
 		return &object.Integer{Value: int64(len(arg.Value))}
 	case *object.Array:
 		return &object.Integer{Value: int64(len(arg.Elements))}
+	case *object.Hash:
+		return &object.Integer{Value: int64(arg.Len())}
+	case *object.Range:
+		return &object.Integer{Value: arg.Len()}
 	default:
 		return newError(ErrTypeNotSupported, "len(%T)", arg.Type())
 	}
@@ -100,6 +137,20 @@ var fnPush = func(args ...object.Object) object.Object {
 	return &object.Array{Elements: newArr}
 }
 
+// fnAppend adds elements to the end of an array in place and returns the array.
+// Unlike push, it does not copy the array, so it takes amortized constant time.
+var fnAppend = func(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError(ErrTooFewArgs, "want=1.. got=%d", len(args))
+	}
+	arr, ok := args[0].(*object.Array)
+	if !ok {
+		return newError(ErrArrayNeeded, "append(%s)", args[0].Type())
+	}
+	arr.Elements = append(arr.Elements, args[1:]...)
+	return arr
+}
+
 var fnPop = func(args ...object.Object) object.Object {
 	if errObj := hasNArgs(1, args...); errObj != nil {
 		return errObj
@@ -124,6 +175,199 @@ var fnPuts = func(args ...object.Object) object.Object {
 	return NULL
 }
 
+var fnKeys = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	if args[0].Type() != object.HASH_OBJ {
+		return newError(ErrHashNeeded, "keys(%T)", args[0].Type())
+	}
+	hash := args[0].(*object.Hash)
+	elems := make([]object.Object, 0, hash.Len())
+	for _, pair := range hash.Pairs() {
+		elems = append(elems, pair.Key)
+	}
+	return &object.Array{Elements: elems}
+}
+
+var fnValues = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	if args[0].Type() != object.HASH_OBJ {
+		return newError(ErrHashNeeded, "values(%T)", args[0].Type())
+	}
+	hash := args[0].(*object.Hash)
+	elems := make([]object.Object, 0, hash.Len())
+	for _, pair := range hash.Pairs() {
+		elems = append(elems, pair.Value)
+	}
+	return &object.Array{Elements: elems}
+}
+
+var fnPut = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(3, args...); errObj != nil {
+		return errObj
+	}
+	if args[0].Type() != object.HASH_OBJ {
+		return newError(ErrHashNeeded, "put(%T)", args[0].Type())
+	}
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError(ErrUnusableAsHashKey, "%s", args[1].Type())
+	}
+	newHash := args[0].(*object.Hash).Copy()
+	newHash.Set(key, args[2])
+	return newHash
+}
+
+var fnDelete = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(2, args...); errObj != nil {
+		return errObj
+	}
+	if args[0].Type() != object.HASH_OBJ {
+		return newError(ErrHashNeeded, "delete(%T)", args[0].Type())
+	}
+	key, ok := args[1].(object.Hashable)
+	if !ok {
+		return newError(ErrUnusableAsHashKey, "%s", args[1].Type())
+	}
+	newHash := args[0].(*object.Hash).Copy()
+	newHash.Delete(key)
+	return newHash
+}
+
+// fnRange returns range(stop), range(start, stop) or range(start, stop, step).
+var fnRange = func(args ...object.Object) object.Object {
+	if len(args) < 1 {
+		return newError(ErrTooFewArgs, "want=1..3 got=%d", len(args))
+	} else if len(args) > 3 {
+		return newError(ErrTooManyArgs, "want=1..3 got=%d", len(args))
+	}
+	ns := make([]int64, len(args))
+	for i, arg := range args {
+		n, ok := arg.(*object.Integer)
+		if !ok {
+			return newError(ErrTypeNotSupported, "range(%s)", arg.Type())
+		}
+		ns[i] = n.Value
+	}
+	r := &object.Range{Step: 1}
+	switch len(ns) {
+	case 1:
+		r.Stop = ns[0]
+	case 2:
+		r.Start, r.Stop = ns[0], ns[1]
+	case 3:
+		r.Start, r.Stop, r.Step = ns[0], ns[1], ns[2]
+	}
+	if r.Step == 0 {
+		return newError(ErrInvalidArgument, "range step must not be 0")
+	}
+	return r
+}
+
+// fnInt converts a number to Integer truncating toward zero, or parses a String.
+var fnInt = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return floatToInteger("int", math.Trunc(arg.Value))
+	case *object.String:
+		v, err := strconv.ParseInt(strings.TrimSpace(arg.Value), 10, 64)
+		if err != nil {
+			return newError(ErrInvalidArgument, "int(%q)", arg.Value)
+		}
+		return &object.Integer{Value: v}
+	default:
+		return newError(ErrTypeNotSupported, "int(%s)", arg.Type())
+	}
+}
+
+// fnFloat converts a number to Float, or parses a String.
+var fnFloat = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return &object.Float{Value: float64(arg.Value)}
+	case *object.Float:
+		return arg
+	case *object.String:
+		v, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+		if err != nil {
+			return newError(ErrInvalidArgument, "float(%q)", arg.Value)
+		}
+		return &object.Float{Value: v}
+	default:
+		return newError(ErrTypeNotSupported, "float(%s)", arg.Type())
+	}
+}
+
+// fnRound rounds half away from zero. round(x) returns an Integer and round(x, digits) returns a Float.
+var fnRound = func(args ...object.Object) object.Object {
+	if len(args) == 2 {
+		v, ok := toFloat(args[0])
+		digits, isInt := args[1].(*object.Integer)
+		if !ok || !isInt {
+			return newError(ErrTypeNotSupported, "round(%s, %s)", args[0].Type(), args[1].Type())
+		}
+		p := math.Pow(10, float64(digits.Value))
+		return &object.Float{Value: math.Round(v*p) / p}
+	}
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return floatToInteger("round", math.Round(arg.Value))
+	default:
+		return newError(ErrTypeNotSupported, "round(%s)", arg.Type())
+	}
+}
+
+// fnFloor returns the greatest Integer less than or equal to the argument.
+var fnFloor = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	switch arg := args[0].(type) {
+	case *object.Integer:
+		return arg
+	case *object.Float:
+		return floatToInteger("floor", math.Floor(arg.Value))
+	default:
+		return newError(ErrTypeNotSupported, "floor(%s)", arg.Type())
+	}
+}
+
+// fnError makes an error value for throw.
+var fnError = func(args ...object.Object) object.Object {
+	if errObj := hasNArgs(1, args...); errObj != nil {
+		return errObj
+	}
+	msg, ok := args[0].(*object.String)
+	if !ok {
+		return newError(ErrTypeNotSupported, "error(%s)", args[0].Type())
+	}
+	return newErrorHash(DefaultErrorKind, msg.Value)
+}
+
+// floatToInteger converts an integral float64 to Integer if it is representable.
+func floatToInteger(name string, v float64) object.Object {
+	if math.IsNaN(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return newError(ErrInvalidArgument, "%s(%v) out of INTEGER range", name, v)
+	}
+	return &object.Integer{Value: int64(v)}
+}
+
 func hasNArgs(n int, args ...object.Object) object.Object {
 	if len(args) == n {
 		return nil
@@ -57,6 +57,12 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`push([1], [1], [1])`, evaluator.ErrTooManyArgs},
 		{`push(1, 1)`, evaluator.ErrArrayNeeded},
 
+		{`append([1], 2, 3)`, &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, &object.Integer{Value: 2}, &object.Integer{Value: 3}}}},
+		{`let a = []; append(a, 1); append(a, 2); len(a)`, 2},
+		{`let a = [1]; append(a) == a`, true},
+		{`append()`, evaluator.ErrTooFewArgs},
+		{`append(1, 1)`, evaluator.ErrArrayNeeded},
+
 		{`pop([1])`, "[]"},
 		{`pop(["hello world", 2])`, `["hello world"]`},
 		{`pop([1, 2, 3])`, "[1, 2]"},
@@ -70,6 +76,63 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`puts(1, 2)`, evaluator.NULL},
 		{`puts(1, 2, 3)`, evaluator.NULL},
 		{`puts("hello", "world")`, evaluator.NULL},
+
+		{`len({})`, 0},
+		{`len({"a": 1, "b": 2})`, 2},
+
+		{`keys({"a": 1, 2: true})`, &object.Array{Elements: []object.Object{&object.String{Value: "a"}, &object.Integer{Value: 2}}}},
+		{`keys({})`, &object.Array{}},
+		{`keys([])`, evaluator.ErrHashNeeded},
+		{`keys()`, evaluator.ErrTooFewArgs},
+
+		{`values({"a": 1, 2: true})`, &object.Array{Elements: []object.Object{&object.Integer{Value: 1}, evaluator.TRUE}}},
+		{`values(1)`, evaluator.ErrHashNeeded},
+		{`values({}, {})`, evaluator.ErrTooManyArgs},
+
+		{`put({}, "a", 1)["a"]`, 1},
+		{`let h = {"a": 1}; put(h, "a", 2); h["a"]`, 1},
+		{`len(put({"a": 1}, "b", 2))`, 2},
+		{`put({}, fn(){}, 1)`, evaluator.ErrUnusableAsHashKey},
+		{`put([], 1, 1)`, evaluator.ErrHashNeeded},
+		{`put({}, 1)`, evaluator.ErrTooFewArgs},
+
+		{`len(delete({"a": 1, "b": 2}, "a"))`, 1},
+		{`len(delete({"a": 1}, "x"))`, 1},
+		{`let h = {"a": 1}; delete(h, "a"); h["a"]`, 1},
+		{`delete({}, [])`, evaluator.ErrUnusableAsHashKey},
+		{`delete(1, 1)`, evaluator.ErrHashNeeded},
+
+		{`len(range(10))`, 10},
+		{`len(range(2, 5))`, 3},
+		{`len(range(10, 0, -3))`, 4},
+		{`len(range(5, 0))`, 0},
+		{`range()`, evaluator.ErrTooFewArgs},
+		{`range(1, 2, 3, 4)`, evaluator.ErrTooManyArgs},
+		{`range("1")`, evaluator.ErrTypeNotSupported},
+		{`range(0, 10, 0)`, evaluator.ErrInvalidArgument},
+
+		{`int(3)`, 3},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(" 42 ")`, 42},
+		{`int("4.2")`, evaluator.ErrInvalidArgument},
+		{`int(1e19)`, evaluator.ErrInvalidArgument},
+		{`int(true)`, evaluator.ErrTypeNotSupported},
+		{`int(float("1.5e2"))`, 150},
+		{`int(float(7))`, 7},
+		{`float("abc")`, evaluator.ErrInvalidArgument},
+		{`float([])`, evaluator.ErrTypeNotSupported},
+		{`round(2.5)`, 3},
+		{`round(-2.5)`, -3},
+		{`round(2.4)`, 2},
+		{`round(7)`, 7},
+		{`round(1.2345, 2) == 1.23`, true},
+		{`round(1.5, "2")`, evaluator.ErrTypeNotSupported},
+		{`round()`, evaluator.ErrTooFewArgs},
+		{`floor(2.7)`, 2},
+		{`floor(-2.1)`, -3},
+		{`floor(5)`, 5},
+		{`floor("5")`, evaluator.ErrTypeNotSupported},
 	}
 	for _, c := range cases {
 		t.Run(c.input, func(t *testing.T) {
@@ -77,6 +140,8 @@ func TestBuiltinFunctions(t *testing.T) {
 			switch want := c.want.(type) {
 			case int:
 				testIntegerObject(t, ev, int64(want))
+			case bool:
+				testBooleanObject(t, ev, want)
 			case object.Null:
 				testNullObject(t, ev)
 			case *object.Array:
@@ -1,8 +1,11 @@
 package evaluator
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/ebiiim/monkey/ast"
 	"github.com/ebiiim/monkey/object"
@@ -16,6 +19,12 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Loop control signals.
+var (
+	breakSignal    = &object.Break{}
+	continueSignal = &object.Continue{}
+)
+
 // Error values.
 var (
 	ErrTypeMismatch              = errors.New("type mismatch")
@@ -23,95 +32,257 @@ var (
 	ErrIdentifierNotFound        = errors.New("identifier not found")
 	ErrIsNotFunction             = errors.New("not a function")
 	ErrIndexOperatorNotSupported = errors.New("index operator not supported")
+	ErrUnusableAsHashKey         = errors.New("unusable as hash key")
+	ErrDivisionByZero            = errors.New("division by zero")
+	ErrIntegerOverflow           = errors.New("integer overflow")
+	ErrIndexOutOfRange           = errors.New("index out of range")
+	ErrNotIterable               = errors.New("not iterable")
 )
 
-// Eval evaluates the program recursively.
+// Options configures an Evaluator.
+// Limits are counted over the lifetime of the Evaluator; zero values mean no limit unless noted.
+type Options struct {
+	// CheckedArithmetic makes integer overflow an ErrIntegerOverflow instead of wrapping around.
+	CheckedArithmetic bool
+	// Context stops the evaluation with ErrEvaluationCanceled when it is done.
+	Context context.Context
+	// MaxSteps is the maximum number of evaluated nodes (ErrStepLimit).
+	MaxSteps int
+	// MaxCallDepth is the maximum depth of function calls (ErrCallDepthExceeded).
+	// 0 means DefaultMaxCallDepth and a negative value means no limit.
+	// Tail calls do not deepen the calls, so infinite tail recursion is only stopped by MaxSteps or Context.
+	MaxCallDepth int
+	// MaxAllocBytes is an approximate budget of allocated bytes (ErrAllocLimit).
+	MaxAllocBytes int
+	// Dir is the directory that relative imports in the main program are resolved against.
+	// The working directory is used if it is empty.
+	Dir string
+	// File is the name of the file of the main program in Dir, if any.
+	// Importing it is reported as ErrImportCycle instead of evaluating it again as a module.
+	File string
+	// ModulePath lists the directories searched for imports that are not relative, like MONKEYPATH.
+	ModulePath []string
+	// NoPrelude makes NewEnvironment and imported modules start without the prelude.
+	NoPrelude bool
+	// Hook is called before each statement if it is not nil.
+	Hook Hook
+}
+
+// Evaluator evaluates nodes with Options.
+type Evaluator struct {
+	opts   Options
+	done   <-chan struct{}
+	stack  []object.Frame // active function calls, outermost first
+	file   string         // source of the nodes being evaluated as in object.Error.File
+	depth  int
+	steps  int
+	allocs int
+
+	modules   map[string]*object.Module // imported modules by file
+	importing []importFrame             // modules being evaluated, outermost first
+
+	tailBodies map[*ast.BlockStatement]bool // function bodies whose tail calls are marked
+	tailCalls  map[*ast.CallExpression]bool // calls in tail position
+}
+
+// New initializes an Evaluator.
+func New(opts Options) *Evaluator {
+	e := &Evaluator{opts: opts}
+	if opts.Context != nil {
+		e.done = opts.Context.Done()
+	}
+	return e
+}
+
+// SetFile names the source of the programs passed to Eval from now on as in object.Error.File.
+// It is empty by default for the main program.
+func (e *Evaluator) SetFile(file string) {
+	e.file = file
+}
+
+// Eval evaluates the program recursively with the default Options.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(Options{}).Eval(node, env)
+}
+
+// Eval evaluates the program recursively.
+// Errors are returned as *object.Error with the position of the failing node and the active function calls.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var obj object.Object
+	if errObj := e.checkStep(); errObj != nil {
+		obj = errObj
+	} else {
+		obj = e.eval(node, env)
+		if errObj := e.allocNode(node, obj); errObj != nil {
+			obj = errObj
+		}
+	}
+	if errObj, ok := obj.(*object.Error); ok && errObj.Row == 0 {
+		e.locateError(errObj, node)
+	}
+	return obj
+}
+
+// locateError sets the position of node and the current stack to errObj.
+func (e *Evaluator) locateError(errObj *object.Error, node ast.Node) {
+	if call, ok := node.(*ast.CallExpression); ok {
+		node = call.Function
+	}
+	errObj.File = e.file
+	errObj.Row, errObj.Col = node.Pos()
+	n := len(e.stack)
+	if n > maxStackFrames {
+		n = maxStackFrames
+	}
+	errObj.Stack = make([]object.Frame, n)
+	for i := range errObj.Stack {
+		errObj.Stack[i] = e.stack[len(e.stack)-1-i]
+	}
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	// statements
 	case *ast.Program:
-		return evalProgram(node.Statements, env)
+		return e.evalProgram(node.Statements, env)
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.Eval(node.Expression, env)
 	case *ast.BlockStatement:
-		return evalBlockStatements(node.Statements, env)
+		return e.evalBlockStatements(node.Statements, env)
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.Eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
+		if _, ok := val.(*object.ReturnValue); ok {
+			return val // a tail call
+		}
 		return &object.ReturnValue{Value: val}
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.Eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
+	case *ast.ExportStatement:
+		return e.Eval(node.Statement, env)
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+	case *ast.TryStatement:
+		return e.evalTryStatement(node, env)
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+	case *ast.BreakStatement:
+		return breakSignal
+	case *ast.ContinueStatement:
+		return continueSignal
 	// expressions
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
 	case *ast.BooleanLiteral:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpressions(node.Operator, right)
+		return e.evalPrefixExpressions(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		if node.Operator == token.AND || node.Operator == token.OR {
+			return e.evalLogicalExpression(node, env)
+		}
+		left := e.Eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.Eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpressions(node.Operator, left, right)
+		return e.evalInfixExpressions(node.Operator, left, right)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 	case *ast.FunctionLiteral:
-		params := node.Parameters
-		body := node.Body
-		return &object.Function{Env: env, Parameters: params, Body: body}
+		return &object.Function{
+			Env:        env,
+			Name:       node.Name,
+			File:       e.file,
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       node.Body,
+		}
 	case *ast.CallExpression:
-		fn := Eval(node.Function, env)
+		if isCallTo(node, "quote") {
+			return e.evalQuote(node, env)
+		}
+		fn := e.Eval(node.Function, env)
 		if isError(fn) {
 			return fn
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(fn, args)
+		if fu, ok := fn.(*object.Function); ok {
+			if e.tailCalls[node] {
+				if errObj := checkArity(fu, len(args)); errObj != nil {
+					return errObj
+				}
+				return &object.ReturnValue{Value: &tailCall{fn: fu, args: args, node: node}}
+			}
+			row, col := node.Function.Pos()
+			e.stack = append(e.stack, object.Frame{Name: fu.Name, File: e.file, Row: row, Col: col})
+			defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+		}
+		return e.applyFunction(fn, args)
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 	case *ast.ArrayLiteral:
-		elems := evalExpressions(node.Elements, env)
+		elems := e.evalExpressions(node.Elements, env)
 		if len(elems) == 1 && isError(elems[0]) {
 			return elems[0]
 		}
 		return &object.Array{Elements: elems}
+	case *ast.MacroLiteral:
+		return evalMacroLiteral(node)
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
+	case *ast.ImportExpression:
+		return e.evalImportExpression(node, env)
 	case *ast.IndexExpression:
-		l := Eval(node.Left, env)
+		l := e.Eval(node.Left, env)
 		if isError(l) {
 			return l
 		}
-		idx := Eval(node.Index, env)
+		idx := e.Eval(node.Index, env)
 		if isError(idx) {
 			return idx
 		}
 		return evalIndexExpression(l, idx)
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 	}
 	return nil
 }
 
-func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	var obj object.Object
 	for _, stmt := range stmts {
-		obj = Eval(stmt, env)
+		e.beforeStatement(stmt, env)
+		obj = e.Eval(stmt, env)
 		// break if return or error
 		switch result := obj.(type) {
 		case *object.ReturnValue:
@@ -123,22 +294,118 @@ func evalProgram(stmts []ast.Statement, env *object.Environment) object.Object {
 	return obj
 }
 
-func evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *Evaluator) evalBlockStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var obj object.Object
 	for _, stmt := range stmts {
-		obj = Eval(stmt, env)
+		e.beforeStatement(stmt, env)
+		obj = e.Eval(stmt, env)
 		if obj == nil {
 			continue
 		}
-		// break if return or error
-		objType := obj.Type()
-		if objType == object.RETURN_VALUE_OBJ || objType == object.ERROR_OBJ {
+		// break if return, error or loop control
+		switch obj.Type() {
+		case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
 			return obj
 		}
 	}
 	return obj
 }
 
+func (e *Evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		cond := e.Eval(node.Condition, env)
+		if isError(cond) {
+			return cond
+		}
+		if !isTruthy(cond) {
+			return NULL
+		}
+		if result, done := e.evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs the body in a new environment for each element so closures capture their own variable.
+func (e *Evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	next, errObj := iterate(iterable)
+	if errObj != nil {
+		return errObj
+	}
+	for elem, ok := next(); ok; elem, ok = next() {
+		if errObj := e.alloc(sizeEnv); errObj != nil {
+			return errObj
+		}
+		loopEnv := object.NewEnclosedEnvironment(env)
+		loopEnv.Set(node.Variable.Value, elem)
+		if result, done := e.evalLoopBody(node.Body, loopEnv); done {
+			return result
+		}
+	}
+	return NULL
+}
+
+// evalLoopBody runs the body once and reports whether the loop is done with the result.
+func (e *Evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	obj := e.Eval(body, env)
+	switch obj.(type) {
+	case *object.Break:
+		return NULL, true
+	case *object.ReturnValue, *object.Error:
+		return obj, true
+	}
+	return nil, false
+}
+
+// iterate returns a function that yields elements of an Array, characters of a String,
+// integers of a Range, or keys of a Hash.
+func iterate(obj object.Object) (func() (object.Object, bool), *object.Error) {
+	var i int64
+	switch o := obj.(type) {
+	case *object.Array:
+		return func() (object.Object, bool) {
+			if i >= int64(len(o.Elements)) {
+				return nil, false
+			}
+			i++
+			return o.Elements[i-1], true
+		}, nil
+	case *object.String:
+		runes := []rune(o.Value)
+		return func() (object.Object, bool) {
+			if i >= int64(len(runes)) {
+				return nil, false
+			}
+			i++
+			return &object.String{Value: string(runes[i-1])}, true
+		}, nil
+	case *object.Range:
+		n := o.Len()
+		return func() (object.Object, bool) {
+			if i >= n {
+				return nil, false
+			}
+			i++
+			return &object.Integer{Value: o.At(i - 1)}, true
+		}, nil
+	case *object.Hash:
+		pairs := o.Pairs()
+		return func() (object.Object, bool) {
+			if i >= int64(len(pairs)) {
+				return nil, false
+			}
+			i++
+			return pairs[i-1].Key, true
+		}, nil
+	default:
+		return nil, newError(ErrNotIterable, "%s", obj.Type())
+	}
+}
+
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if obj, ok := env.Get(node.Value); ok {
 		return obj
@@ -149,12 +416,12 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return newError(ErrIdentifierNotFound, "%s", node.Value)
 }
 
-func evalPrefixExpressions(op string, right object.Object) object.Object {
+func (e *Evaluator) evalPrefixExpressions(op string, right object.Object) object.Object {
 	switch op {
 	case token.BANG:
 		return evalBangOperatorExpression(right)
 	case token.MINUS:
-		return evalMinusOperatorExpression(right)
+		return e.evalMinusOperatorExpression(right)
 	default:
 		return newError(ErrUnknownOperator, "%s%s", op, right.Type())
 	}
@@ -173,17 +440,26 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 	}
 }
 
-func evalMinusOperatorExpression(right object.Object) object.Object {
+func (e *Evaluator) evalMinusOperatorExpression(right object.Object) object.Object {
+	if f, ok := right.(*object.Float); ok {
+		return &object.Float{Value: -f.Value}
+	}
 	if right.Type() != object.INTEGER_OBJ {
 		return newError(ErrUnknownOperator, "-%s", right.Type())
 	}
-	return &object.Integer{Value: -right.(*object.Integer).Value}
+	v := right.(*object.Integer).Value
+	if e.opts.CheckedArithmetic && v == math.MinInt64 {
+		return newError(ErrIntegerOverflow, "-%d", v)
+	}
+	return &object.Integer{Value: -v}
 }
 
-func evalInfixExpressions(op string, left, right object.Object) object.Object {
+func (e *Evaluator) evalInfixExpressions(op string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
-		return evalIntegerInfixExpression(op, left, right)
+		return e.evalIntegerInfixExpression(op, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(op, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(op, left, right)
 	// compare memory addresses because we have just one TRUE and FALSE
@@ -198,22 +474,102 @@ func evalInfixExpressions(op string, left, right object.Object) object.Object {
 	}
 }
 
-func evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
+// evalLogicalExpression evaluates && and ||.
+// The right operand is evaluated only if the left one does not decide the result.
+func (e *Evaluator) evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := e.Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if isTruthy(left) == (node.Operator == token.OR) {
+		return nativeBoolToBooleanObject(isTruthy(left))
+	}
+	right := e.Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func (e *Evaluator) evalIntegerInfixExpression(op string, left, right object.Object) object.Object {
 	l := left.(*object.Integer).Value
 	r := right.(*object.Integer).Value
 	switch op {
 	case token.PLUS:
+		if e.opts.CheckedArithmetic && addOverflows(l, r) {
+			return newError(ErrIntegerOverflow, "%d + %d", l, r)
+		}
 		return &object.Integer{Value: l + r}
 	case token.MINUS:
+		if e.opts.CheckedArithmetic && subOverflows(l, r) {
+			return newError(ErrIntegerOverflow, "%d - %d", l, r)
+		}
 		return &object.Integer{Value: l - r}
 	case token.ASTERISK:
+		if e.opts.CheckedArithmetic && mulOverflows(l, r) {
+			return newError(ErrIntegerOverflow, "%d * %d", l, r)
+		}
 		return &object.Integer{Value: l * r}
 	case token.SLASH:
+		if r == 0 {
+			return newError(ErrDivisionByZero, "%d / %d", l, r)
+		}
+		if e.opts.CheckedArithmetic && l == math.MinInt64 && r == -1 {
+			return newError(ErrIntegerOverflow, "%d / %d", l, r)
+		}
 		return &object.Integer{Value: l / r}
+	case token.PERCENT:
+		if r == 0 {
+			return newError(ErrDivisionByZero, "%d %% %d", l, r)
+		}
+		return &object.Integer{Value: l % r}
+	case token.LT:
+		return nativeBoolToBooleanObject(l < r)
+	case token.GT:
+		return nativeBoolToBooleanObject(l > r)
+	case token.LTE:
+		return nativeBoolToBooleanObject(l <= r)
+	case token.GTE:
+		return nativeBoolToBooleanObject(l >= r)
+	case token.EQ:
+		return nativeBoolToBooleanObject(l == r)
+	case token.NEQ:
+		return nativeBoolToBooleanObject(l != r)
+	default:
+		return newError(ErrUnknownOperator, "%s %s %s", left.Type(), op, right.Type())
+	}
+}
+
+// evalFloatInfixExpression evaluates an operation on two numbers where at least one is a Float.
+// Integers are converted to Float.
+func evalFloatInfixExpression(op string, left, right object.Object) object.Object {
+	l, _ := toFloat(left)
+	r, _ := toFloat(right)
+	switch op {
+	case token.PLUS:
+		return &object.Float{Value: l + r}
+	case token.MINUS:
+		return &object.Float{Value: l - r}
+	case token.ASTERISK:
+		return &object.Float{Value: l * r}
+	case token.SLASH:
+		if r == 0 {
+			return newError(ErrDivisionByZero, "%s / %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: l / r}
+	case token.PERCENT:
+		if r == 0 {
+			return newError(ErrDivisionByZero, "%s %% %s", left.Inspect(), right.Inspect())
+		}
+		return &object.Float{Value: math.Mod(l, r)}
 	case token.LT:
 		return nativeBoolToBooleanObject(l < r)
 	case token.GT:
 		return nativeBoolToBooleanObject(l > r)
+	case token.LTE:
+		return nativeBoolToBooleanObject(l <= r)
+	case token.GTE:
+		return nativeBoolToBooleanObject(l >= r)
 	case token.EQ:
 		return nativeBoolToBooleanObject(l == r)
 	case token.NEQ:
@@ -223,6 +579,41 @@ func evalIntegerInfixExpression(op string, left, right object.Object) object.Obj
 	}
 }
 
+func isNumber(obj object.Object) bool {
+	_, ok := toFloat(obj)
+	return ok
+}
+
+// toFloat converts an Integer or a Float to float64.
+func toFloat(obj object.Object) (float64, bool) {
+	switch o := obj.(type) {
+	case *object.Integer:
+		return float64(o.Value), true
+	case *object.Float:
+		return o.Value, true
+	default:
+		return 0, false
+	}
+}
+
+func addOverflows(l, r int64) bool {
+	return (r > 0 && l > math.MaxInt64-r) || (r < 0 && l < math.MinInt64-r)
+}
+
+func subOverflows(l, r int64) bool {
+	return (r < 0 && l > math.MaxInt64+r) || (r > 0 && l < math.MinInt64+r)
+}
+
+func mulOverflows(l, r int64) bool {
+	if l == 0 || r == 0 {
+		return false
+	}
+	if (l == -1 && r == math.MinInt64) || (r == -1 && l == math.MinInt64) {
+		return true
+	}
+	return (l*r)/r != l
+}
+
 func evalStringInfixExpression(op string, left, right object.Object) object.Object {
 	l := left.(*object.String).Value
 	r := right.(*object.String).Value
@@ -230,32 +621,40 @@ func evalStringInfixExpression(op string, left, right object.Object) object.Obje
 	case token.PLUS:
 		return &object.String{Value: l + r}
 	case token.EQ:
-		return &object.Boolean{Value: l == r}
+		return nativeBoolToBooleanObject(l == r)
 	case token.NEQ:
-		return &object.Boolean{Value: l != r}
+		return nativeBoolToBooleanObject(l != r)
+	case token.LT:
+		return nativeBoolToBooleanObject(l < r)
+	case token.GT:
+		return nativeBoolToBooleanObject(l > r)
+	case token.LTE:
+		return nativeBoolToBooleanObject(l <= r)
+	case token.GTE:
+		return nativeBoolToBooleanObject(l >= r)
 	default:
 		return newError(ErrUnknownOperator, "%s %s %s", left.Type(), op, right.Type())
 	}
 }
 
-func evalIfExpression(e *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(e.Condition, env)
+func (e *Evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(e.Consequence, env)
+		return e.Eval(ie.Consequence, env)
 	}
-	if e.Alternative != nil {
-		return Eval(e.Alternative, env)
+	if ie.Alternative != nil {
+		return e.Eval(ie.Alternative, env)
 	}
 	return NULL
 }
 
-func evalExpressions(e []ast.Expression, env *object.Environment) []object.Object {
+func (e *Evaluator) evalExpressions(exprs []ast.Expression, env *object.Environment) []object.Object {
 	var objs []object.Object
-	for _, expr := range e {
-		obj := Eval(expr, env)
+	for _, expr := range exprs {
+		obj := e.Eval(expr, env)
 		if isError(obj) {
 			return []object.Object{obj}
 		}
@@ -264,30 +663,127 @@ func evalExpressions(e []ast.Expression, env *object.Environment) []object.Objec
 	return objs
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// Apply calls fn, a Function or a Builtin, with args.
+func (e *Evaluator) Apply(fn object.Object, args ...object.Object) object.Object {
+	return e.applyFunction(fn, args)
+}
+
+func (e *Evaluator) applyFunction(fn object.Object, args []object.Object) object.Object {
 	switch fu := fn.(type) {
 	case *object.Function:
-		eEnv := extendFunctionEnv(fu, args)
-		ev := Eval(fu.Body, eEnv)
-		return unwrapReturnValue(ev)
+		if errObj := e.checkCallDepth(); errObj != nil {
+			return errObj
+		}
+		// Tail calls replace the function in this loop. The frame of the latest one is kept above the caller's.
+		tailFrame := false
+		defer func(file string) {
+			if tailFrame {
+				e.stack = e.stack[:len(e.stack)-1]
+			}
+			e.file = file
+		}(e.file)
+		for {
+			if errObj := e.alloc(sizeEnv + sizeElement*len(args)); errObj != nil {
+				return errObj
+			}
+			eEnv, errObj := e.extendFunctionEnv(fu, args)
+			if errObj != nil {
+				return errObj
+			}
+			e.markTailCalls(fu.Body)
+			e.file = fu.File
+			e.depth++
+			ev := e.Eval(fu.Body, eEnv)
+			e.depth--
+			result := unwrapReturnValue(ev)
+			tc, ok := result.(*tailCall)
+			if !ok {
+				return result
+			}
+			row, col := tc.node.Function.Pos()
+			frame := object.Frame{Name: tc.fn.Name, File: fu.File, Row: row, Col: col}
+			if tailFrame {
+				e.stack[len(e.stack)-1] = frame
+			} else {
+				e.stack = append(e.stack, frame)
+				tailFrame = true
+			}
+			fu, args = tc.fn, tc.args
+		}
 	case *object.Builtin:
-		return fu.Fn(args...)
+		before := 0
+		if len(args) > 0 {
+			before = sizeOf(args[0])
+		}
+		result := fu.Fn(args...)
+		size := sizeOf(result)
+		if len(args) > 0 && result == args[0] {
+			size -= before // only grown in place, e.g. by append
+		}
+		if errObj := e.alloc(size); errObj != nil {
+			return errObj
+		}
+		return result
 	default:
 		return newError(ErrIsNotFunction, "%s", fn.Type())
 	}
 }
 
-func extendFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
+// extendFunctionEnv binds args to the parameters of fn.
+// Default values are evaluated in the new environment so they can refer to preceding parameters.
+func (e *Evaluator) extendFunctionEnv(fn *object.Function, args []object.Object) (*object.Environment, *object.Error) {
+	if errObj := checkArity(fn, len(args)); errObj != nil {
+		return nil, errObj
+	}
 	eEnv := object.NewEnclosedEnvironment(fn.Env)
-	for i, paramName := range fn.Parameters {
-		eEnv.Set(paramName.Value, args[i])
+	for i, param := range fn.Parameters {
+		if i < len(args) {
+			eEnv.Set(param.Value, args[i])
+			continue
+		}
+		val := e.Eval(fn.Defaults[i], eEnv)
+		if errObj, ok := val.(*object.Error); ok {
+			return nil, errObj
+		}
+		eEnv.Set(param.Value, val)
+	}
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		eEnv.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
-	return eEnv
+	return eEnv, nil
+}
+
+func checkArity(fn *object.Function, n int) *object.Error {
+	min := 0
+	for min < len(fn.Parameters) && (min >= len(fn.Defaults) || fn.Defaults[min] == nil) {
+		min++
+	}
+	max := len(fn.Parameters)
+	var want string
+	switch {
+	case fn.Rest != nil:
+		want = fmt.Sprintf(">=%d", min)
+	case min == max:
+		want = fmt.Sprintf("=%d", min)
+	default:
+		want = fmt.Sprintf("=%d..%d", min, max)
+	}
+	if n < min {
+		return newError(ErrTooFewArgs, "want%s got=%d", want, n)
+	}
+	if fn.Rest == nil && n > max {
+		return newError(ErrTooManyArgs, "want%s got=%d", want, n)
+	}
+	return nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
 	if returnValue, ok := obj.(*object.ReturnValue); ok {
-		return returnValue
+		return returnValue.Value
 	}
 	return obj
 }
@@ -296,6 +792,10 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	case left.Type() == object.MODULE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalModuleMember(left.(*object.Module), index.(*object.String).Value)
 	default:
 		return newError(ErrIndexOperatorNotSupported, "%s", left.Type())
 	}
@@ -311,6 +811,114 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrObj.Elements[idx]
 }
 
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	hashObj := hash.(*object.Hash)
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError(ErrUnusableAsHashKey, "%s", index.Type())
+	}
+	val, ok := hashObj.Get(key)
+	if !ok {
+		return NULL
+	}
+	return val
+}
+
+// evalAssignExpression updates the nearest binding or the element in place and returns the new value.
+func (e *Evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var cur object.Object
+		if node.Operator != "=" {
+			if cur = evalIdentifier(target, env); isError(cur) {
+				return cur
+			}
+		}
+		val := e.evalAssignValue(node, cur, env)
+		if isError(val) {
+			return val
+		}
+		if !env.Assign(target.Value, val) {
+			return newError(ErrIdentifierNotFound, "%s", target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := e.Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		idx := e.Eval(target.Index, env)
+		if isError(idx) {
+			return idx
+		}
+		var cur object.Object
+		if node.Operator != "=" {
+			if cur = evalIndexExpression(left, idx); isError(cur) {
+				return cur
+			}
+		}
+		val := e.evalAssignValue(node, cur, env)
+		if isError(val) {
+			return val
+		}
+		return assignIndex(left, idx, val)
+	default:
+		return newError(ErrUnknownOperator, "%s %s", node.Target, node.Operator)
+	}
+}
+
+// evalAssignValue evaluates the value and applies the compound operator to cur.
+func (e *Evaluator) evalAssignValue(node *ast.AssignExpression, cur object.Object, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if isError(val) || node.Operator == "=" {
+		return val
+	}
+	return e.evalInfixExpressions(strings.TrimSuffix(node.Operator, "="), cur, val)
+}
+
+func assignIndex(left, index, val object.Object) object.Object {
+	switch l := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError(ErrIndexOperatorNotSupported, "%s[%s]", left.Type(), index.Type())
+		}
+		if i.Value < 0 || i.Value >= int64(len(l.Elements)) {
+			return newError(ErrIndexOutOfRange, "%d (len=%d)", i.Value, len(l.Elements))
+		}
+		l.Elements[i.Value] = val
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(ErrUnusableAsHashKey, "%s", index.Type())
+		}
+		l.Set(key, val)
+	default:
+		return newError(ErrIndexOperatorNotSupported, "%s", left.Type())
+	}
+	return val
+}
+
+func (e *Evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		k := e.Eval(pair.Key, env)
+		if isError(k) {
+			return k
+		}
+		key, ok := k.(object.Hashable)
+		if !ok {
+			return newError(ErrUnusableAsHashKey, "%s", k.Type())
+		}
+		v := e.Eval(pair.Value, env)
+		if isError(v) {
+			return v
+		}
+		hash.Set(key, v)
+	}
+	return hash
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -2,6 +2,7 @@ package evaluator_test
 
 import (
 	"errors"
+	"math"
 	"testing"
 
 	"github.com/ebiiim/monkey/evaluator"
@@ -39,6 +40,44 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"1.5", "1.5"},
+		{"-0.15", "-0.15"},
+		{"1e3", "1000.0"},
+		{"1.5 + 1.5", "3.0"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{"100 * 0.15", "15.0"},
+		{"0.15 * 100", "15.0"},
+		{"1 / 2", "0"},
+		{"1 / 2.0", "0.5"},
+		{"3.0 - 1", "2.0"},
+		{"1e300 * 1e10", "+Inf"},
+		{"1 < 1.5", "true"},
+		{"1.5 > 2", "false"},
+		{"1 == 1.0", "true"},
+		{"1.0 != 1", "false"},
+		{"0.5 == 0.5", "true"},
+		{"let x = 10; x *= 1.5; x", "15.0"},
+		{"1.0 / 0", "ERROR: 1:5 division by zero: 1.0 / 0"},
+		{"1.5 + true", "ERROR: 1:5 type mismatch: FLOAT + BOOLEAN"},
+		{`1.5 + "a"`, "ERROR: 1:5 type mismatch: FLOAT + STRING"},
+		{`{1.5: 1}`, "ERROR: 1:1 unusable as hash key: FLOAT"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	cases := []struct {
 		input string
@@ -73,6 +112,59 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestComparisonAndLogicalOperators(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"1 <= 1", "true"},
+		{"2 <= 1", "false"},
+		{"1 >= 2", "false"},
+		{"2 >= 2", "true"},
+		{"1.5 <= 1", "false"},
+		{"1 >= 0.5", "true"},
+		{`"a" < "b"`, "true"},
+		{`"b" > "abc"`, "true"},
+		{`"abc" <= "abd"`, "true"},
+		{`"abc" >= "abd"`, "false"},
+		{"7 % 3", "1"},
+		{"-7 % 3", "-1"},
+		{"7.5 % 2", "1.5"},
+		{"10 + 7 % 4 * 2", "16"},
+		{"let x = 10; x % 4", "2"},
+		{"true && true", "true"},
+		{"true && false", "false"},
+		{"false || true", "true"},
+		{"false || false", "false"},
+		{`1 && "a"`, "true"},
+		{"if (false) { 1 } || 0", "true"},
+		{"false && undefined", "false"},
+		{"true || undefined", "true"},
+		{"true && undefined", "ERROR: 1:9 identifier not found: undefined"},
+		{"let n = 0; let f = fn() { n += 1; true }; false && f(); true || f(); true && f(); n", "1"},
+		{"1 < 2 && 2 < 3 || false", "true"},
+		{`"a" == "b" && true`, "false"},
+		{`"a" != "a" || false`, "false"},
+		{`"a" == "a" && "a" != "b"`, "true"},
+		{`if ("a" == "b") { 1 } else { 2 }`, "2"},
+		{`if ("a" != "a") { 1 } else { 2 }`, "2"},
+		{`let i = 0; while ("a" == "b") { i += 1; break }; i`, "0"},
+		{"5 % 0", "ERROR: 1:3 division by zero: 5 % 0"},
+		{"5.0 % 0", "ERROR: 1:5 division by zero: 5.0 % 0"},
+		{`"a" % "b"`, "ERROR: 1:5 unknown operator: STRING % STRING"},
+		{"true <= false", "ERROR: 1:6 unknown operator: BOOLEAN <= BOOLEAN"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	cases := []struct {
 		input string
@@ -154,6 +246,20 @@ func TestErrorHandling(t *testing.T) {
 		{"if (10 > 1) { if (10 > 1) { return true + false; 1; } return 1; }", evaluator.ErrUnknownOperator, "unknown operator: BOOLEAN + BOOLEAN"},
 		{"foobar;", evaluator.ErrIdentifierNotFound, "identifier not found: foobar"},
 		{`"hello " - "world";`, evaluator.ErrUnknownOperator, "unknown operator: STRING - STRING"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, evaluator.ErrUnusableAsHashKey, "unusable as hash key: FUNCTION"},
+		{`{fn(x) { x }: 1};`, evaluator.ErrUnusableAsHashKey, "unusable as hash key: FUNCTION"},
+		{"1 / 0", evaluator.ErrDivisionByZero, "division by zero: 1 / 0"},
+		{"let zero = 0; 10 / zero; 5", evaluator.ErrDivisionByZero, "division by zero: 10 / 0"},
+		{"x = 1", evaluator.ErrIdentifierNotFound, "identifier not found: x"},
+		{"x += 1", evaluator.ErrIdentifierNotFound, "identifier not found: x"},
+		{"let f = fn() { y = 1 }; f()", evaluator.ErrIdentifierNotFound, "identifier not found: y"},
+		{"let a = [1]; a[1] = 2", evaluator.ErrIndexOutOfRange, "index out of range: 1 (len=1)"},
+		{"let a = [1]; a[-1] = 2", evaluator.ErrIndexOutOfRange, "index out of range: -1 (len=1)"},
+		{`let a = [1]; a["0"] = 2`, evaluator.ErrIndexOperatorNotSupported, "index operator not supported: ARRAY[STRING]"},
+		{`let h = {}; h[[]] = 2`, evaluator.ErrUnusableAsHashKey, "unusable as hash key: ARRAY"},
+		{`let s = "ab"; s[0] = "c"`, evaluator.ErrIndexOperatorNotSupported, "index operator not supported: STRING"},
+		{`let s = "ab"; s -= "c"`, evaluator.ErrUnknownOperator, "unknown operator: STRING - STRING"},
+		{"let a = 1; a /= 0", evaluator.ErrDivisionByZero, "division by zero: 1 / 0"},
 	}
 	for _, c := range cases {
 		t.Run(c.input, func(t *testing.T) {
@@ -175,6 +281,167 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestAssignExpressions(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = 2", "2"},
+		{"let x = 1; let y = 1; x = y = 3; x + y", "6"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", "3"},
+		{"let n = 0; let inc = fn() { n = n + 1 }; inc(); inc(); n", "2"},
+		{"let n = 0; let f = fn(n) { n = 5 }; f(1); n", "0"},
+		{"let f = fn() { let n = 1; n = 2; n }; f()", "2"},
+		{"let a = [1, 2, 3]; a[0] = 10; a[2] += 5; a", "[10, 2, 8, ]"},
+		{"let a = [1, 2]; let b = a; b[0] = 5; a[0]", "5"},
+		{`let h = {"a": 1}; h["a"] = 2; h["b"] = 3; h["a"] *= 10; h`, "{a: 20, b: 3}"},
+		{"let a = [[1], [2]]; a[1][0] = 3; a", "[[1, ], [3, ], ]"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+func TestLoops(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"let i = 0; while (i < 5) { i += 1 }; i", "5"},
+		{"let i = 0; while (i < 5) { i += 1 }", "null"},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break } }; i", "3"},
+		{"let i = 0; let n = 0; while (i < 10) { i += 1; if (i > 3) { continue } n += i }; n", "6"},
+		{"let s = 0; for (x in [1, 2, 3]) { s += x }; s", "6"},
+		{"let s = 0; for (x in range(5)) { s += x }; s", "10"},
+		{"let s = 0; for (x in range(10, 0, -2)) { s += x }; s", "30"},
+		{`let s = ""; for (c in "héllo") { s = c + s }; s`, "olléh"},
+		{`let s = ""; for (k in {"a": 1, "b": 2}) { s += k }; s`, "ab"},
+		{"let s = 0; for (x in range(10)) { if (x / 2 * 2 == x) { continue } s += x }; s", "25"},
+		{"let s = 0; for (x in range(100)) { if (x == 4) { break } s += x }; s", "6"},
+		{"let f = fn() { for (x in range(10)) { if (x == 3) { return x * 10 } } 0 }; f()", "30"},
+		{"let f = fn() { while (true) { return 1 } }; f()", "1"},
+		{"let s = 0; for (x in range(3)) { for (y in range(3)) { if (y > x) { break } s += 1 } }; s", "6"},
+		{"let fs = []; for (x in range(3)) { fs = push(fs, fn() { x }) }; fs[0]() + fs[1]() + fs[2]()", "3"},
+		{"let x = 100; for (x in range(3)) { }; x", "100"},
+		{"for (x in range(3)) { let y = x }; y", "ERROR: 1:36 identifier not found: y"},
+		{"for (x in 1) { }", "ERROR: 1:1 not iterable: INTEGER"},
+		{"while (1 + true) { }", "ERROR: 1:10 type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1, 2]; let s = 0; for (x in a) { a[1] = 5; s += x }; s", "6"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+func TestLoopLimits(t *testing.T) {
+	program := parser.New(lexer.New("while (true) { }")).ParseProgram()
+	ev := evaluator.New(evaluator.Options{MaxSteps: 1000}).Eval(program, object.NewEnvironment())
+	if errObj, ok := ev.(*object.Error); !ok || !errors.Is(errObj, evaluator.ErrStepLimit) {
+		t.Errorf("want=%v got=%v", evaluator.ErrStepLimit, ev)
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	cases := []struct {
+		input     string
+		wantError string
+		wantTrace string
+	}{
+		{"5 + true;", "1:3 type mismatch: INTEGER + BOOLEAN", ""},
+		{"let a = 1;\n  foobar;", "2:3 identifier not found: foobar", ""},
+		{"let a = 1;\nlen(1)", "2:1 type not supported: len(object.Type)", ""},
+		{`let add = fn(a, b) {
+	a + b
+};
+let twice = fn(x) {
+	add(x, x)
+};
+twice("a");
+twice(1) + twice(true);`, "2:7 unknown operator: BOOLEAN + BOOLEAN", "\tat add (called at 5:5)\n\tat twice (called at 8:12)\n"},
+		{`let f = fn(a) { a };
+fn() { f() }();`, "2:8 too few arguments: want=1 got=0", "\tat <anonymous> (called at 2:1)\n"},
+		{`let f = fn(n) { if (n == 0) { 1 + true } else { 0 + f(n - 1) } };
+f(5)`, "1:33 type mismatch: INTEGER + BOOLEAN", "\tat f (called at 1:53)\n\t... repeated 4 more times\n\tat f (called at 2:1)\n"},
+		{`let f = fn(n) { if (n == 0) { 1 + true } else { 0 + f(n - 1) } };
+f(2)`, "1:33 type mismatch: INTEGER + BOOLEAN", "\tat f (called at 1:53)\n\tat f (called at 1:53)\n\tat f (called at 2:1)\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if errObj.Error() != c.wantError {
+				t.Errorf("wrong error want=%q got=%q", c.wantError, errObj.Error())
+			}
+			if errObj.StackTrace() != c.wantTrace {
+				t.Errorf("wrong stack trace want=%q got=%q", c.wantTrace, errObj.StackTrace())
+			}
+			var asErr *object.Error
+			if err := error(errObj); !errors.As(err, &asErr) || !errors.Is(err, errors.Unwrap(errObj.Message)) {
+				t.Errorf("errors.As or errors.Is failed for %v", err)
+			}
+		})
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    int64
+		wantErr error
+	}{
+		{"9223372036854775807 + 1", math.MinInt64, evaluator.ErrIntegerOverflow},
+		{"-9223372036854775807 - 2", math.MaxInt64, evaluator.ErrIntegerOverflow},
+		{"9223372036854775807 * 2", -2, evaluator.ErrIntegerOverflow},
+		{"4611686018427387904 * -2", math.MinInt64, nil},
+		{"-(-9223372036854775807 - 1)", math.MinInt64, evaluator.ErrIntegerOverflow},
+		{"(-9223372036854775807 - 1) / -1", math.MinInt64, evaluator.ErrIntegerOverflow},
+		{"(-9223372036854775807 - 1) * -1", math.MinInt64, evaluator.ErrIntegerOverflow},
+		{"9223372036854775807 - 1", math.MaxInt64 - 1, nil},
+		{"-9223372036854775807 + -1", math.MinInt64, nil},
+		{"3037000499 * 3037000499", 3037000499 * 3037000499, nil},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			// wrap around by default
+			testIntegerObject(t, evaluator.Eval(program, object.NewEnvironment()), c.want)
+
+			ev := evaluator.New(evaluator.Options{CheckedArithmetic: true}).Eval(program, object.NewEnvironment())
+			if c.wantErr == nil {
+				testIntegerObject(t, ev, c.want)
+				return
+			}
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj.Message, c.wantErr) {
+				t.Errorf("wrong error type want=%+v got=%+v", c.wantErr, errObj.Message)
+			}
+		})
+	}
+}
+
 func TestLetStatement(t *testing.T) {
 	cases := []struct {
 		input string
@@ -234,6 +501,69 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestFunctionArity(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"fn(a, b) { a }(1)", evaluator.ErrTooFewArgs, "too few arguments: want=2 got=1"},
+		{"fn(a) { a }(1, 2)", evaluator.ErrTooManyArgs, "too many arguments: want=1 got=2"},
+		{"fn() { 1 }(1)", evaluator.ErrTooManyArgs, "too many arguments: want=0 got=1"},
+		{"fn(a, b = 2) { a }()", evaluator.ErrTooFewArgs, "too few arguments: want=1..2 got=0"},
+		{"fn(a, b = 2) { a }(1, 2, 3)", evaluator.ErrTooManyArgs, "too many arguments: want=1..2 got=3"},
+		{"fn(a, ...rest) { a }()", evaluator.ErrTooFewArgs, "too few arguments: want>=1 got=0"},
+		{"fn(a = foo) { a }()", evaluator.ErrIdentifierNotFound, "identifier not found: foo"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj.Message, c.wantErr) {
+				t.Errorf("wrong error type want=%+v got=%+v", c.wantErr, errObj.Message)
+			}
+			if errObj.Message.Error() != c.wantMsg {
+				t.Errorf("wrong error message want=%s got=%s", c.wantMsg, errObj.Message)
+			}
+		})
+	}
+}
+
+func TestDefaultAndRestParameters(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"let f = fn(a, b = 2) { a + b }; f(1)", "3"},
+		{"let f = fn(a, b = 2) { a + b }; f(1, 10)", "11"},
+		{"let f = fn(a, b = a * 2) { a + b }; f(3)", "9"},
+		{"let x = 100; let f = fn(a = x) { a }; let x = 1; f()", "1"}, // evaluated at call time
+		{"let f = fn(...rest) { rest }; f()", "[]"},
+		{"let f = fn(a, ...rest) { len(rest) }; f(1, 2, 3)", "2"},
+		{"let f = fn(a, ...rest) { rest[1] }; f(1, 2, 3)", "3"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1)[1]", "2"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, len(rest)] }; f(1, 5, 6, 7)[2]", "2"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			if ev == nil || ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%+v", c.want, ev)
+			}
+		})
+	}
+}
+
+func TestReturnFromNestedCall(t *testing.T) {
+	input := "let f = fn() { return 1; }; let g = fn() { f(); 2 }; g() + f()"
+	testIntegerObject(t, testEval(input), 3)
+}
+
 func TestStringLiteral(t *testing.T) {
 	input := `"hello world"`
 	ev := testEval(input)
@@ -289,6 +619,71 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+{
+	"one": 10 - 9,
+	two: 1 + 1,
+	"thr" + "ee": 6 / 2,
+	4: 4,
+	true: 5,
+	false: 6
+}`
+	ev := testEval(input)
+	hash, ok := ev.(*object.Hash)
+	if !ok {
+		t.Fatalf("object is not Hash but %T (%+v)", ev, ev)
+	}
+	want := []struct {
+		key   object.Hashable
+		value int64
+	}{
+		{&object.String{Value: "one"}, 1},
+		{&object.String{Value: "two"}, 2},
+		{&object.String{Value: "three"}, 3},
+		{&object.Integer{Value: 4}, 4},
+		{evaluator.TRUE, 5},
+		{evaluator.FALSE, 6},
+	}
+	if hash.Len() != len(want) {
+		t.Fatalf("hash.Len() want=%d got=%d", len(want), hash.Len())
+	}
+	for i, w := range want {
+		pair := hash.Pairs()[i]
+		if pair.Key.HashKey() != w.key.HashKey() {
+			t.Errorf("pairs[%d] wrong key want=%s got=%s", i, w.key.Inspect(), pair.Key.Inspect())
+		}
+		testIntegerObject(t, pair.Value, w.value)
+	}
+}
+
+func TestHashIndexExpressions(t *testing.T) {
+	cases := []struct {
+		input string
+		want  interface{}
+	}{
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			ev := testEval(c.input)
+			switch want := c.want.(type) {
+			case int:
+				testIntegerObject(t, ev, int64(want))
+			case nil:
+				testNullObject(t, ev)
+			}
+		})
+	}
+}
+
 func testEval(input string) object.Object {
 	p := parser.New(lexer.New(input))
 	program := p.ParseProgram()
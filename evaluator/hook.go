@@ -0,0 +1,27 @@
+package evaluator
+
+import (
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/object"
+)
+
+// Hook is notified by the evaluator as it runs, e.g. by a debugger.
+type Hook interface {
+	// BeforeStatement is called before stmt at row and col is evaluated in env.
+	// Statements in functions of the prelude and in imported modules are included.
+	BeforeStatement(stmt ast.Statement, row, col int, env *object.Environment)
+}
+
+func (e *Evaluator) beforeStatement(stmt ast.Statement, env *object.Environment) {
+	if e.opts.Hook == nil {
+		return
+	}
+	row, col := stmt.Pos()
+	e.opts.Hook.BeforeStatement(stmt, row, col, env)
+}
+
+// Stack returns the active function calls, outermost first.
+// Each Frame is the call site and the name of the called function.
+func (e *Evaluator) Stack() []object.Frame {
+	return append([]object.Frame(nil), e.stack...)
+}
@@ -0,0 +1,63 @@
+package evaluator_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+// recorder records each statement with its position, the names in its environment and the called functions.
+type recorder struct {
+	ev   *evaluator.Evaluator
+	got  []string
+	stmt ast.Statement
+}
+
+func (r *recorder) BeforeStatement(stmt ast.Statement, row, col int, env *object.Environment) {
+	var calls []string
+	for _, f := range r.ev.Stack() {
+		calls = append(calls, f.Name)
+	}
+	r.got = append(r.got, fmt.Sprintf("%d:%d [%s] [%s]", row, col, strings.Join(env.Names(), " "), strings.Join(calls, " ")))
+	r.stmt = stmt
+}
+
+func TestHook(t *testing.T) {
+	input := `let f = fn(x) {
+  let y = x + 1;
+  y * 2
+};
+let a = f(1);
+for (i in [1]) { a }`
+	want := []string{
+		"1:1 [] []",
+		"5:1 [f] []",
+		"2:3 [x] [f]",
+		"3:3 [x y] [f]",
+		"6:1 [a f] []",
+		"6:18 [i] []",
+	}
+	program := parser.New(lexer.New(input)).ParseProgram()
+	r := &recorder{}
+	r.ev = evaluator.New(evaluator.Options{NoPrelude: true, Hook: r})
+	if result := r.ev.Eval(program, r.ev.NewEnvironment()); result != nil && result.Type() == object.ERROR_OBJ {
+		t.Fatal(result.Inspect())
+	}
+	if !reflect.DeepEqual(r.got, want) {
+		t.Errorf("want=%q got=%q", want, r.got)
+	}
+	last := program.Statements[2].(*ast.ForStatement).Body.Statements[0]
+	if r.stmt != last {
+		t.Errorf("last statement want=%v got=%v", last, r.stmt)
+	}
+	if len(r.ev.Stack()) != 0 {
+		t.Errorf("stack after the program want=[] got=%v", r.ev.Stack())
+	}
+}
@@ -0,0 +1,93 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/object"
+)
+
+// Limit errors.
+var (
+	ErrStepLimit          = errors.New("step limit exceeded")
+	ErrCallDepthExceeded  = errors.New("call depth exceeded")
+	ErrAllocLimit         = errors.New("allocation limit exceeded")
+	ErrEvaluationCanceled = errors.New("evaluation canceled")
+)
+
+// DefaultMaxCallDepth is used when Options.MaxCallDepth is 0.
+// It keeps deep recursion well below the Go stack limit.
+const DefaultMaxCallDepth = 10000
+
+// maxStackFrames is the number of innermost frames kept in an error.
+const maxStackFrames = 100
+
+// Approximate sizes in bytes used to account allocations.
+const (
+	sizeObject  = 16 // Integer, Boolean, etc.
+	sizeElement = 16 // an interface value in an Array or a Hash
+	sizeEnv     = 64
+)
+
+// checkStep counts an evaluation step and checks the context and the step limit.
+func (e *Evaluator) checkStep() *object.Error {
+	e.steps++
+	if e.done != nil {
+		select {
+		case <-e.done:
+			return newError(ErrEvaluationCanceled, "%v after %d steps", e.opts.Context.Err(), e.steps)
+		default:
+		}
+	}
+	if e.opts.MaxSteps > 0 && e.steps > e.opts.MaxSteps {
+		return newError(ErrStepLimit, "max=%d", e.opts.MaxSteps)
+	}
+	return nil
+}
+
+// checkCallDepth checks the depth of function calls before entering one more.
+func (e *Evaluator) checkCallDepth() *object.Error {
+	max := e.opts.MaxCallDepth
+	if max == 0 {
+		max = DefaultMaxCallDepth
+	}
+	if max > 0 && e.depth >= max {
+		return newError(ErrCallDepthExceeded, "max=%d", max)
+	}
+	return nil
+}
+
+// alloc accounts n bytes and checks the allocation limit.
+func (e *Evaluator) alloc(n int) *object.Error {
+	e.allocs += n
+	if e.opts.MaxAllocBytes > 0 && e.allocs > e.opts.MaxAllocBytes {
+		return newError(ErrAllocLimit, "max=%d bytes", e.opts.MaxAllocBytes)
+	}
+	return nil
+}
+
+// allocNode accounts the object that node evaluated to if the node creates a new one.
+func (e *Evaluator) allocNode(node ast.Node, obj object.Object) *object.Error {
+	switch node.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral,
+		*ast.FunctionLiteral, *ast.PrefixExpression, *ast.InfixExpression:
+		return e.alloc(sizeOf(obj))
+	}
+	return nil
+}
+
+// sizeOf estimates the bytes held by obj itself, not by its elements.
+func sizeOf(obj object.Object) int {
+	switch o := obj.(type) {
+	case *object.Null, *object.Boolean:
+		return 0 // shared
+	case *object.String:
+		return sizeObject + len(o.Value)
+	case *object.Array:
+		return sizeObject + sizeElement*len(o.Elements)
+	case *object.Hash:
+		return sizeObject + 2*sizeElement*o.Len()
+	default:
+		return sizeObject
+	}
+}
@@ -0,0 +1,106 @@
+package evaluator_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+const (
+	infiniteRecursion = "let f = fn() { 1 + f() }; f();" // not a tail call
+	infiniteTailCall  = "let f = fn() { f() }; f();"     // never deepens the calls
+	infiniteLoop      = "let f = fn(n) { if (n > 0) { f(n - 1) } }; let g = fn() { f(100); g() }; g();"
+	growingString     = `let f = fn(s) { f(s + s) }; f("abcd");`
+)
+
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	cases := []struct {
+		name    string
+		input   string
+		opts    evaluator.Options
+		wantErr error
+		wantMsg string
+	}{
+		{"default call depth", infiniteRecursion, evaluator.Options{},
+			evaluator.ErrCallDepthExceeded, "1:20 call depth exceeded: max=10000"},
+		{"call depth", infiniteRecursion, evaluator.Options{MaxCallDepth: 5},
+			evaluator.ErrCallDepthExceeded, "1:20 call depth exceeded: max=5"},
+		{"steps", "1 + 2 * 3", evaluator.Options{MaxSteps: 5},
+			evaluator.ErrStepLimit, "1:5 step limit exceeded: max=5"},
+		{"steps in recursion", infiniteRecursion, evaluator.Options{MaxSteps: 100, MaxCallDepth: -1},
+			evaluator.ErrStepLimit, "1:20 step limit exceeded: max=100"},
+		{"steps in tail recursion", infiniteTailCall, evaluator.Options{MaxSteps: 100},
+			evaluator.ErrStepLimit, "1:16 step limit exceeded: max=100"},
+		{"allocs", growingString, evaluator.Options{MaxAllocBytes: 1 << 20},
+			evaluator.ErrAllocLimit, "1:21 allocation limit exceeded: max=1048576 bytes"},
+		{"array literal", "[1, 2, 3, 4, 5, 6, 7, 8]", evaluator.Options{MaxAllocBytes: 128},
+			evaluator.ErrAllocLimit, "1:1 allocation limit exceeded: max=128 bytes"},
+		{"canceled", "1", evaluator.Options{Context: canceled},
+			evaluator.ErrEvaluationCanceled, "1:1 evaluation canceled: context canceled after 1 steps"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(c.opts).Eval(program, object.NewEnvironment())
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj, c.wantErr) {
+				t.Errorf("wrong error want=%v got=%v", c.wantErr, errObj)
+			}
+			if errObj.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, errObj.Error())
+			}
+			if len(errObj.Stack) > 100 {
+				t.Errorf("stack trace too long got=%d", len(errObj.Stack))
+			}
+		})
+	}
+}
+
+func TestLimitsNotExceeded(t *testing.T) {
+	input := `let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(10);`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	opts := evaluator.Options{
+		Context:       context.Background(),
+		MaxSteps:      100000,
+		MaxCallDepth:  20,
+		MaxAllocBytes: 1 << 20,
+	}
+	testIntegerObject(t, evaluator.New(opts).Eval(program, object.NewEnvironment()), 55)
+}
+
+func TestDeadline(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		opts  evaluator.Options
+	}{
+		{"loop", infiniteLoop, evaluator.Options{MaxCallDepth: -1}},
+		{"tail recursion", infiniteTailCall, evaluator.Options{}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			c.opts.Context = ctx
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(c.opts).Eval(program, object.NewEnvironment())
+			errObj, ok := ev.(*object.Error)
+			if !ok || !errors.Is(errObj, evaluator.ErrEvaluationCanceled) {
+				t.Fatalf("wrong error want=%v got=%v", evaluator.ErrEvaluationCanceled, ev)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package evaluator
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/token"
+)
+
+// Macro errors.
+var (
+	ErrNotQuote         = errors.New("macro must return a quote")
+	ErrCannotUnquote    = errors.New("cannot unquote")
+	ErrMacroNotTopLevel = errors.New("macro must be bound by a top-level let")
+)
+
+// evalQuote returns the argument of quote(...) without evaluating it.
+// unquote(...) calls in the argument are replaced with the nodes of their values.
+func (e *Evaluator) evalQuote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if errObj := hasNArgsNode(call, 1); errObj != nil {
+		return errObj
+	}
+	var errObj *object.Error
+	node := ast.Modify(ast.Copy(call.Arguments[0]), func(node ast.Node) ast.Node {
+		unquote, ok := node.(*ast.CallExpression)
+		if !ok || errObj != nil || !isCallTo(unquote, "unquote") {
+			return node
+		}
+		if errObj = hasNArgsNode(unquote, 1); errObj != nil {
+			e.locateError(errObj, unquote)
+			return node
+		}
+		obj := e.Eval(unquote.Arguments[0], env)
+		if isError(obj) {
+			errObj = obj.(*object.Error)
+			return node
+		}
+		converted := objectToNode(obj, unquote.Token)
+		if converted == nil {
+			errObj = newError(ErrCannotUnquote, "%s", obj.Type())
+			e.locateError(errObj, unquote)
+			return node
+		}
+		return converted
+	})
+	if errObj != nil {
+		return errObj
+	}
+	return &object.Quote{Node: node}
+}
+
+// objectToNode converts obj to a literal at the position of tok, or returns nil if it cannot.
+// Quoted nodes are copied because a quote may be spliced more than once
+// and nodes are told apart by identity, e.g. calls in tail position.
+func objectToNode(obj object.Object, tok token.Token) ast.Node {
+	switch obj := obj.(type) {
+	case *object.Quote:
+		return ast.Copy(obj.Node)
+	case *object.Integer:
+		t := token.New(token.INT, strconv.FormatInt(obj.Value, 10), tok.Row, tok.Col)
+		return &ast.IntegerLiteral{Token: t, Value: obj.Value}
+	case *object.Float:
+		t := token.New(token.FLOAT, obj.Inspect(), tok.Row, tok.Col)
+		return &ast.FloatLiteral{Token: t, Value: obj.Value}
+	case *object.String:
+		t := token.New(token.STRING, obj.Value, tok.Row, tok.Col)
+		return &ast.StringLiteral{Token: t, Value: obj.Value}
+	case *object.Boolean:
+		t := token.New(token.FALSE, token.FALSE, tok.Row, tok.Col)
+		if obj.Value {
+			t = token.New(token.TRUE, token.TRUE, tok.Row, tok.Col)
+		}
+		return &ast.BooleanLiteral{Token: t, Value: obj.Value}
+	default:
+		return nil
+	}
+}
+
+// hasNArgsNode checks the number of arguments of a call that is not evaluated as a function.
+func hasNArgsNode(call *ast.CallExpression, n int) *object.Error {
+	switch {
+	case len(call.Arguments) < n:
+		return newError(ErrTooFewArgs, "want=%d got=%d", n, len(call.Arguments))
+	case len(call.Arguments) > n:
+		return newError(ErrTooManyArgs, "want=%d got=%d", n, len(call.Arguments))
+	}
+	return nil
+}
+
+func isCallTo(call *ast.CallExpression, name string) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// DefineMacros removes top-level "let name = macro(...) {...};" statements from program
+// and binds the macros to env.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	stmts := make([]ast.Statement, 0, len(program.Statements))
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{Env: env, Parameters: lit.Parameters, Body: lit.Body})
+				continue
+			}
+		}
+		stmts = append(stmts, stmt)
+	}
+	program.Statements = stmts
+}
+
+// ExpandMacros expands the macros in env with the default Options.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	return New(Options{}).ExpandMacros(program, env)
+}
+
+// ExpandMacros replaces calls to the macros defined in env with the nodes they return.
+// Arguments are passed to macros as quotes. The error is an *object.Error.
+func (e *Evaluator) ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, error) {
+	var errObj *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || errObj != nil {
+			return node
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		obj, ok := env.Get(ident.Value)
+		if !ok {
+			return node
+		}
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			return node
+		}
+		var expandedNode ast.Node
+		expandedNode, errObj = e.expandMacro(ident.Value, macro, call)
+		if errObj != nil {
+			return node
+		}
+		return expandedNode
+	})
+	if errObj != nil {
+		return nil, errObj
+	}
+	return expanded, nil
+}
+
+func (e *Evaluator) expandMacro(name string, macro *object.Macro, call *ast.CallExpression) (ast.Node, *object.Error) {
+	if errObj := hasNArgsNode(call, len(macro.Parameters)); errObj != nil {
+		e.locateError(errObj, call)
+		return nil, errObj
+	}
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+	row, col := call.Function.Pos()
+	e.stack = append(e.stack, object.Frame{Name: name, File: e.file, Row: row, Col: col})
+	result := unwrapReturnValue(e.Eval(macro.Body, env))
+	e.stack = e.stack[:len(e.stack)-1]
+	switch result := result.(type) {
+	case *object.Error:
+		return nil, result
+	case *object.Quote:
+		return result.Node, nil
+	default:
+		errObj := newError(ErrNotQuote, "%s returned %s", name, result.Type())
+		e.locateError(errObj, call)
+		return nil, errObj
+	}
+}
+
+// evalMacroLiteral reports a macro literal left after DefineMacros.
+func evalMacroLiteral(node *ast.MacroLiteral) object.Object {
+	return newError(ErrMacroNotTopLevel, "macro(%s)", ast.FormatParameters(node.Parameters, nil, nil))
+}
@@ -0,0 +1,144 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestQuoteUnquote(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"quote(5)", "QUOTE(5)"},
+		{"quote(5 + 8)", "QUOTE((5 + 8))"},
+		{"quote(foobar)", "QUOTE(foobar)"},
+		{"quote(unquote(4))", "QUOTE(4)"},
+		{"quote(unquote(4 + 4))", "QUOTE(8)"},
+		{"quote(8 + unquote(4 + 4))", "QUOTE((8 + 8))"},
+		{"let foo = 8; quote(unquote(foo) * foo)", "QUOTE((8 * foo))"},
+		{"quote(unquote(1.5))", "QUOTE(1.5)"},
+		{`quote(unquote("a" + "b"))`, "QUOTE(ab)"},
+		{"quote(unquote(true))", "QUOTE(true)"},
+		{"quote(unquote(1 == 2))", "QUOTE(false)"},
+		{"quote(unquote(quote(4 + 4)))", "QUOTE((4 + 4))"},
+		{"let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))", "QUOTE((8 + (4 + 4)))"},
+		{"let f = fn(x) { quote(unquote(x) + 1) }; [f(1), f(2)]", "[QUOTE((1 + 1)), QUOTE((2 + 1)), ]"},
+		{"quote(unquote([1]))", "ERROR: 1:7 cannot unquote: ARRAY"},
+		{"quote(unquote(1, 2))", "ERROR: 1:7 too many arguments: want=1 got=2"},
+		{"quote()", "ERROR: 1:1 too few arguments: want=1 got=0"},
+		{"macro(x) { x }", "ERROR: 1:1 macro must be bound by a top-level let: macro(x)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{}).Eval(program, object.NewEnvironment())
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `let number = 1;
+let function = fn(x, y) { x + y };
+let mymacro = macro(x, y) { x + y; };`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	env := object.NewEnvironment()
+	evaluator.DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements want=2 got=%d", len(program.Statements))
+	}
+	for _, name := range []string{"number", "function"} {
+		if _, ok := env.Get(name); ok {
+			t.Errorf("%s should not be defined", name)
+		}
+	}
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("not a Macro got=%T", obj)
+	}
+	if len(macro.Parameters) != 2 || macro.Parameters[0].Value != "x" || macro.Parameters[1].Value != "y" {
+		t.Errorf("wrong parameters got=%v", macro.Parameters)
+	}
+	if want := "(x + y)"; macro.Body.String() != want {
+		t.Errorf("wrong body want=%s got=%s", want, macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"let infix = macro() { quote(1 + 2) }; infix()", "(1 + 2)"},
+		{"let reverse = macro(a, b) { quote(unquote(b) - unquote(a)) }; reverse(2 + 2, 10 - 5)", "((10 - 5) - (2 + 2))"},
+		{`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };
+unless(10 > 5, puts("not greater"), puts("greater"))`, `if(!(10 > 5)) puts(not greater)else puts(greater)`},
+		{"let twice = macro(x) { quote(unquote(x) + unquote(x)) }; twice(twice(1))", "((1 + 1) + (1 + 1))"},
+		{"let id = macro(x) { x }; fn() { id(1) }", "fn () 1"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			env := object.NewEnvironment()
+			evaluator.DefineMacros(program, env)
+			expanded, err := evaluator.ExpandMacros(program, env)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expanded.String() != c.want {
+				t.Errorf("want=%s got=%s", c.want, expanded.String())
+			}
+		})
+	}
+}
+
+func TestExpandMacrosErr(t *testing.T) {
+	cases := []struct {
+		input     string
+		wantErr   error
+		wantMsg   string
+		wantTrace string
+	}{
+		{"let m = macro(x) { 1 }; m(1)", evaluator.ErrNotQuote, "1:25 macro must return a quote: m returned INTEGER", ""},
+		{"let m = macro(x) { quote(x) }; m()", evaluator.ErrTooFewArgs, "1:32 too few arguments: want=1 got=0", ""},
+		{"let m = macro() { 1 + true }; m()", evaluator.ErrTypeMismatch, "1:21 type mismatch: INTEGER + BOOLEAN",
+			"\tat m (called at 1:31)\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			env := object.NewEnvironment()
+			evaluator.DefineMacros(program, env)
+			_, err := evaluator.ExpandMacros(program, env)
+			errObj, ok := err.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", err, err)
+			}
+			if !errors.Is(errObj, c.wantErr) {
+				t.Errorf("wrong error want=%v got=%v", c.wantErr, errObj)
+			}
+			if errObj.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, errObj.Error())
+			}
+			if errObj.StackTrace() != c.wantTrace {
+				t.Errorf("wrong stack trace want=%q got=%q", c.wantTrace, errObj.StackTrace())
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package evaluator
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+// Module errors.
+var (
+	ErrModuleNotFound = errors.New("module not found")
+	ErrImportFailed   = errors.New("import failed")
+	ErrImportCycle    = errors.New("import cycle")
+	ErrNotExported    = errors.New("not exported")
+)
+
+// ModuleExt is appended to import paths without an extension.
+const ModuleExt = ".monkey"
+
+// ModulePathEnv is the environment variable that lists directories searched for modules.
+const ModulePathEnv = "MONKEYPATH"
+
+// ModulePathFromEnv returns the directories listed in MONKEYPATH.
+func ModulePathFromEnv() []string {
+	var dirs []string
+	for _, dir := range filepath.SplitList(os.Getenv(ModulePathEnv)) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// importFrame is a module being evaluated.
+type importFrame struct {
+	name string // the path given to import
+	file string
+}
+
+// evalImportExpression evaluates the module once and returns the cached object.Module afterwards.
+func (e *Evaluator) evalImportExpression(node *ast.ImportExpression, env *object.Environment) object.Object {
+	path := e.Eval(node.Path, env)
+	if isError(path) {
+		return path
+	}
+	str, ok := path.(*object.String)
+	if !ok {
+		return newError(ErrInvalidArgument, "import path must be STRING got=%s", path.Type())
+	}
+	name := str.Value
+	file, err := e.resolveModule(name)
+	if err != nil {
+		return newError(ErrModuleNotFound, "%q", name)
+	}
+	if m, ok := e.modules[file]; ok {
+		return m
+	}
+	importing := e.importing
+	if e.opts.File != "" {
+		if main, err := filepath.Abs(filepath.Join(e.opts.Dir, e.opts.File)); err == nil {
+			importing = append([]importFrame{{name: e.opts.File, file: main}}, importing...)
+		}
+	}
+	for i, f := range importing {
+		if f.file == file {
+			var chain []string
+			for _, f := range importing[i:] {
+				chain = append(chain, fmt.Sprintf("%q", f.name))
+			}
+			return newError(ErrImportCycle, "%s -> %q", strings.Join(chain, " -> "), name)
+		}
+	}
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return newError(ErrImportFailed, "%q: %v", name, err)
+	}
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		return newError(ErrImportFailed, "%q: %v", name, errs[0]) // the rest often follow from the first
+	}
+
+	row, col := node.Pos()
+	e.stack = append(e.stack, object.Frame{Name: fmt.Sprintf("import(%q)", name), File: e.file, Row: row, Col: col})
+	e.importing = append(e.importing, importFrame{name: name, file: file})
+	defer func(outer string) {
+		e.stack = e.stack[:len(e.stack)-1]
+		e.importing = e.importing[:len(e.importing)-1]
+		e.file = outer
+	}(e.file)
+	e.file = file
+	macros := object.NewEnvironment()
+	DefineMacros(program, macros)
+	if _, err := e.ExpandMacros(program, macros); err != nil {
+		return err.(*object.Error)
+	}
+	modEnv := e.NewEnvironment()
+	if result := e.Eval(program, modEnv); isError(result) {
+		return result
+	}
+
+	m := &object.Module{Name: name, Exports: make(map[string]object.Object)}
+	for _, stmt := range program.Statements {
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			m.Exports[export.Statement.Name.Value], _ = modEnv.Get(export.Statement.Name.Value)
+		}
+	}
+	if e.modules == nil {
+		e.modules = make(map[string]*object.Module)
+	}
+	e.modules[file] = m
+	return m
+}
+
+// resolveModule finds the file of the module.
+// Paths starting with "./" or "../" are relative to the module they are written in, even in its functions
+// called from elsewhere, or to Options.Dir in the main program.
+// Other relative paths are searched in Options.ModulePath.
+func (e *Evaluator) resolveModule(name string) (string, error) {
+	path := filepath.FromSlash(name)
+	var candidates []string
+	switch {
+	case filepath.IsAbs(path):
+		candidates = []string{path}
+	case strings.HasPrefix(name, "./") || strings.HasPrefix(name, "../"):
+		dir := e.opts.Dir
+		if filepath.IsAbs(e.file) {
+			dir = filepath.Dir(e.file) // a module; other sources are not files
+		}
+		candidates = []string{filepath.Join(dir, path)}
+	default:
+		for _, dir := range e.opts.ModulePath {
+			candidates = append(candidates, filepath.Join(dir, path))
+		}
+	}
+	for _, c := range candidates {
+		if filepath.Ext(c) == "" {
+			c += ModuleExt
+		}
+		if fi, err := os.Stat(c); err == nil && fi.Mode().IsRegular() {
+			return filepath.Abs(c)
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// evalModuleMember returns the exported binding of the module.
+func evalModuleMember(m *object.Module, name string) object.Object {
+	if v, ok := m.Exports[name]; ok {
+		return v
+	}
+	return newError(ErrNotExported, "%s from %q", name, m.Name)
+}
+
+// evalMemberExpression evaluates "m.name" for a Module or a Hash with a string key.
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	switch obj := obj.(type) {
+	case *object.Module:
+		return evalModuleMember(obj, name)
+	case *object.Hash:
+		return evalHashIndexExpression(obj, &object.String{Value: name})
+	default:
+		return newError(ErrIndexOperatorNotSupported, "%s", obj.Type())
+	}
+}
@@ -0,0 +1,175 @@
+package evaluator_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+// writeModules writes files to a temporary directory and returns the directory.
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImport(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"math.monkey":       "let sq = fn(x) { x * x }; export let square = sq; export let two = 2;",
+		"counter.monkey":    `export let state = {"count": 0}; state["count"] += 1;`,
+		"sub/a.monkey":      `export let name = import("./b").name + "a";`,
+		"sub/b.monkey":      `export let name = "b";`,
+		"lib/util.monkey":   `export let greet = fn(s) { "hello " + s };`,
+		"data.txt":          `export let ok = true;`,
+		"isolated.monkey":   "export let f = fn() { secret };",
+		"reexport.monkey":   `let m = import("./math"); export let square = m.square;`,
+		"lib/nested.monkey": `export let v = import("util").greet("lib");`,
+		"sub/lazy.monkey":   `export let f = fn() { import("./b").name };`,
+	})
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{`let m = import("./math"); m.square(3) + m["two"]`, "11"},
+		{`import("./math.monkey").two`, "2"},
+		{`import("./math")`, `module("./math")`},
+		{`let a = import("./counter"); let b = import("./counter"); [a == b, b.state["count"]]`, "[true, 1, ]"},
+		{`import("./sub/a").name`, "ba"},
+		{`import("util").greet("world")`, "hello world"},
+		{`import("nested").v`, "hello lib"},
+		{`import("./data.txt").ok`, "true"},
+		{`import("./reexport").square(4)`, "16"},
+		{`import("./sub/lazy").f()`, "b"},
+		{`let secret = 1; import("./isolated").f()`, "ERROR: 1:23 identifier not found: secret"},
+		{`import("./math").sq`, `ERROR: 1:17 not exported: sq from "./math"`},
+		{`import("./nothing")`, `ERROR: 1:1 module not found: "./nothing"`},
+		{`import("math")`, `ERROR: 1:1 module not found: "math"`},
+		{`import(1)`, "ERROR: 1:1 invalid argument: import path must be STRING got=INTEGER"},
+		{`{"a": 1}.a`, "1"},
+		{`{"a": 1}.b`, "null"},
+		{`[1].a`, "ERROR: 1:4 index operator not supported: ARRAY"},
+	}
+	opts := evaluator.Options{Dir: dir, ModulePath: []string{filepath.Join(dir, "lib")}}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(opts).Eval(program, object.NewEnvironment())
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+func TestImportErr(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"a.monkey":      `let b = import("./b");`,
+		"b.monkey":      `let a = import("./a");`,
+		"self.monkey":   `import("./self");`,
+		"syntax.monkey": "let = 1;",
+		"runtime.monkey": `let f = fn() { 1 + true };
+export let v = f();`,
+	})
+	cases := []struct {
+		input     string
+		wantErr   error
+		wantMsg   string
+		wantTrace string
+		wantFiles []string // of the error and the frames, relative to the directory
+	}{
+		{`import("./a")`, evaluator.ErrImportCycle, `1:9 import cycle: "./a" -> "./b" -> "./a"`,
+			"\tat import(\"./b\") (called at 1:9)\n\tat import(\"./a\") (called at 1:1)\n",
+			[]string{"b.monkey", "a.monkey", ""}},
+		{`import("./self")`, evaluator.ErrImportCycle, `1:1 import cycle: "./self" -> "./self"`,
+			"\tat import(\"./self\") (called at 1:1)\n",
+			[]string{"self.monkey", ""}},
+		{`import("./syntax")`, evaluator.ErrImportFailed, `1:1 import failed: "./syntax": 1:5 expected "IDENT" but got "=" instead (ErrTokenType)`,
+			"",
+			[]string{""}},
+		{"let x = 1;\nimport(\"./runtime\")", evaluator.ErrTypeMismatch, "1:18 type mismatch: INTEGER + BOOLEAN",
+			"\tat f (called at 2:16)\n\tat import(\"./runtime\") (called at 2:1)\n",
+			[]string{"runtime.monkey", "runtime.monkey", ""}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{Dir: dir}).Eval(program, object.NewEnvironment())
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj, c.wantErr) {
+				t.Errorf("wrong error want=%v got=%v", c.wantErr, errObj)
+			}
+			if errObj.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, errObj.Error())
+			}
+			if errObj.StackTrace() != c.wantTrace {
+				t.Errorf("wrong stack trace want=%q got=%q", c.wantTrace, errObj.StackTrace())
+			}
+			files := []string{errObj.File}
+			for _, f := range errObj.Stack {
+				files = append(files, f.File)
+			}
+			for i, want := range c.wantFiles {
+				if want != "" {
+					want = filepath.Join(dir, want)
+				}
+				if i >= len(files) || files[i] != want {
+					t.Errorf("wrong files want=%v got=%v", c.wantFiles, files)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestImportMain(t *testing.T) {
+	dir := writeModules(t, map[string]string{
+		"main.monkey": `import("./c2");`,
+		"c2.monkey":   `import("./main");`,
+	})
+	program := parser.New(lexer.New(`import("./c2");`)).ParseProgram()
+	ev := evaluator.New(evaluator.Options{Dir: dir, File: "main.monkey"}).Eval(program, object.NewEnvironment())
+	errObj, ok := ev.(*object.Error)
+	if !ok || !errors.Is(errObj, evaluator.ErrImportCycle) {
+		t.Fatalf("wrong error want=%v got=%v", evaluator.ErrImportCycle, ev)
+	}
+	if want := `1:1 import cycle: "main.monkey" -> "./c2" -> "./main"`; errObj.Error() != want {
+		t.Errorf("wrong message want=%q got=%q", want, errObj.Error())
+	}
+}
+
+func TestModulePathFromEnv(t *testing.T) {
+	old, ok := os.LookupEnv(evaluator.ModulePathEnv)
+	defer func() {
+		if ok {
+			os.Setenv(evaluator.ModulePathEnv, old)
+		} else {
+			os.Unsetenv(evaluator.ModulePathEnv)
+		}
+	}()
+	os.Setenv(evaluator.ModulePathEnv, "a"+string(os.PathListSeparator)+string(os.PathListSeparator)+"b")
+	got := evaluator.ModulePathFromEnv()
+	if len(got) != 2 || got[0] != "a" || got[1] != "b" {
+		t.Errorf("want=[a b] got=%v", got)
+	}
+}
@@ -0,0 +1,47 @@
+package evaluator
+
+import (
+	_ "embed" // for the prelude
+	"fmt"
+	"sync"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+//go:embed prelude.monkey
+var preludeSrc string
+
+var (
+	preludeOnce    sync.Once
+	preludeProgram *ast.Program
+)
+
+// PreludeFile is the source of the prelude in object.Error.File.
+const PreludeFile = "<prelude>"
+
+// Prelude returns the standard prelude. It is parsed once and shared by all Evaluators.
+func Prelude() *ast.Program {
+	preludeOnce.Do(func() {
+		p := parser.New(lexer.New(preludeSrc))
+		preludeProgram = p.ParseProgram()
+		if err := p.Err(); err != nil {
+			panic(fmt.Sprintf("evaluator: invalid prelude: %v", err))
+		}
+	})
+	return preludeProgram
+}
+
+// NewEnvironment initializes an environment with the prelude loaded unless Options.NoPrelude is set.
+// Loading the prelude does not count against the limits in Options.
+func (e *Evaluator) NewEnvironment() *object.Environment {
+	env := object.NewEnvironment()
+	if !e.opts.NoPrelude {
+		pe := New(Options{NoPrelude: true})
+		pe.file = PreludeFile
+		pe.Eval(Prelude(), env)
+	}
+	return env
+}
@@ -0,0 +1,117 @@
+package evaluator_test
+
+import (
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestPrelude(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"map([1, 2, 3], fn(x) { x * 2 })", "[2, 4, 6, ]"},
+		{"map([], fn(x) { x })", "[]"},
+		{"reduce([1, 2, 3], 10, fn(acc, x) { acc - x })", "4"},
+		{"sum([1, 2, 3, 4])", "10"},
+		{"sum(range(101))", "5050"},
+		{"filter(range(10), fn(x) { x % 3 == 0 })", "[0, 3, 6, 9, ]"},
+		{`filter(["a", "b"], fn(s) { s == "a" })`, "[a, ]"},
+		{"let n = 0; each([1, 2, 3], fn(x) { n += x }); n", "6"},
+		{"each([1], fn(x) { x })", "null"},
+		{`zip([1, 2, 3], ["a", "b"])`, "[[1, a, ], [2, b, ], ]"},
+		{"find([1, 2, 3, 4], fn(x) { x > 2 })", "3"},
+		{"find([1, 2], fn(x) { x > 2 })", "null"},
+		{"any([1, 2, 3], fn(x) { x > 2 })", "true"},
+		{"any([], fn(x) { true })", "false"},
+		{"all([1, 2, 3], fn(x) { x > 0 })", "true"},
+		{"all([1, -2, 3], fn(x) { x > 0 })", "false"},
+		{`all(["a", "b"], fn(s) { s == "a" })`, "false"},
+		{"sort_by([3, 1, 2], fn(x) { x })", "[1, 2, 3, ]"},
+		{"sort_by([3, -1, 2], fn(x) { -x })", "[3, 2, -1, ]"},
+		{`sort_by(["bb", "a", "ccc", "dd"], len)`, "[a, bb, dd, ccc, ]"},
+		{`sort_by([{"n": "x", "k": 2}, {"n": "y", "k": 1}, {"n": "z", "k": 2}], fn(h) { h["k"] })`,
+			"[{n: y, k: 1}, {n: x, k: 2}, {n: z, k: 2}, ]"},
+		{"sort_by([], fn(x) { x })", "[]"},
+		{"let map = 1; map", "1"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := evaluator.New(evaluator.Options{})
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			got := ev.Eval(program, ev.NewEnvironment())
+			if got.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, got.Inspect())
+			}
+		})
+	}
+}
+
+func TestNoPrelude(t *testing.T) {
+	ev := evaluator.New(evaluator.Options{NoPrelude: true})
+	program := parser.New(lexer.New("map([1], fn(x) { x })")).ParseProgram()
+	if got, want := ev.Eval(program, ev.NewEnvironment()).Inspect(), "ERROR: 1:1 identifier not found: map"; got != want {
+		t.Errorf("want=%s got=%s", want, got)
+	}
+}
+
+func TestPreludeIsParsedOnce(t *testing.T) {
+	if evaluator.Prelude() != evaluator.Prelude() {
+		t.Errorf("want the same *ast.Program")
+	}
+}
+
+func TestPreludeNotCountedInLimits(t *testing.T) {
+	ev := evaluator.New(evaluator.Options{MaxSteps: 100, MaxAllocBytes: 1024})
+	program := parser.New(lexer.New("sum([1, 2])")).ParseProgram()
+	testIntegerObject(t, ev.Eval(program, ev.NewEnvironment()), 3)
+}
+
+// TestPreludeScales runs the helpers that build arrays on large ones within an allocation budget
+// that copying the result on every element exceeds by far.
+func TestPreludeScales(t *testing.T) {
+	const n = 10000
+	cases := []struct {
+		input string
+		want  int64
+	}{
+		{"len(map(xs, fn(x) { x }))", n},
+		{"len(filter(xs, fn(x) { true }))", n},
+		{"len(zip(xs, xs))", n},
+		{"sort_by(xs, fn(x) { -x })[0]", n - 1},
+	}
+	xs := &object.Array{}
+	for i := int64(0); i < n; i++ {
+		xs.Elements = append(xs.Elements, &object.Integer{Value: i})
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			ev := evaluator.New(evaluator.Options{MaxAllocBytes: 10000 * n})
+			env := ev.NewEnvironment()
+			env.Set("xs", xs)
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			testIntegerObject(t, ev.Eval(program, env), c.want)
+		})
+	}
+}
+
+func TestPreludeErrorFile(t *testing.T) {
+	ev := evaluator.New(evaluator.Options{})
+	program := parser.New(lexer.New(`sum([1, "a"])`)).ParseProgram()
+	errObj, ok := ev.Eval(program, ev.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatal("want an error")
+	}
+	if errObj.File != evaluator.PreludeFile {
+		t.Errorf("wrong file want=%q got=%q", evaluator.PreludeFile, errObj.File)
+	}
+	if n := len(errObj.Stack); n == 0 || errObj.Stack[n-1].File != "" {
+		t.Errorf("the outermost call is not in the program got=%+v", errObj.Stack)
+	}
+}
@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/object"
+)
+
+// tailCall is a call in tail position that is returned to applyFunction in a ReturnValue instead of being applied,
+// so the trampoline there runs it without growing the Go stack or the call depth.
+type tailCall struct {
+	fn   *object.Function
+	args []object.Object
+	node *ast.CallExpression
+}
+
+var _ object.Object = (*tailCall)(nil)
+
+func (t *tailCall) Type() object.Type { return "TAIL_CALL" }
+func (t *tailCall) Inspect() string   { return "tail call " + t.node.String() }
+
+// markTailCalls records the calls in tail position of a function body the first time it is applied.
+// Calls in try statements are not in tail position because the try must still catch their errors.
+func (e *Evaluator) markTailCalls(body *ast.BlockStatement) {
+	if e.tailBodies[body] {
+		return
+	}
+	if e.tailBodies == nil {
+		e.tailBodies = make(map[*ast.BlockStatement]bool)
+		e.tailCalls = make(map[*ast.CallExpression]bool)
+	}
+	e.tailBodies[body] = true
+	e.markTailBlock(body, true)
+}
+
+// markTailBlock marks the returned calls in block, and the last expression if the value of block is returned.
+func (e *Evaluator) markTailBlock(block *ast.BlockStatement, isValue bool) {
+	for i, stmt := range block.Statements {
+		switch stmt := stmt.(type) {
+		case *ast.ReturnStatement:
+			e.markTailExpression(stmt.ReturnValue, true)
+		case *ast.ExpressionStatement:
+			e.markTailExpression(stmt.Expression, isValue && i == len(block.Statements)-1)
+		case *ast.WhileStatement:
+			e.markTailBlock(stmt.Body, false)
+		case *ast.ForStatement:
+			e.markTailBlock(stmt.Body, false)
+		}
+	}
+}
+
+func (e *Evaluator) markTailExpression(expr ast.Expression, isValue bool) {
+	switch expr := expr.(type) {
+	case *ast.CallExpression:
+		if isValue {
+			e.tailCalls[expr] = true
+		}
+	case *ast.IfExpression:
+		e.markTailBlock(expr.Consequence, isValue)
+		if expr.Alternative != nil {
+			e.markTailBlock(expr.Alternative, isValue)
+		}
+	}
+}
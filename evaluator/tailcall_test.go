@@ -0,0 +1,129 @@
+package evaluator_test
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestTailCalls(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"let count = fn(n, acc) { if (n == 0) { acc } else { count(n - 1, acc + 1) } }; count(100000, 0)", "100000"},
+		{`let f = fn(n) { if (n == 0) { return "done" }; return f(n - 1); }; f(100000)`, "done"},
+		{"let f = fn(n) { if (n > 0) { return f(n - 1) }; n }; f(100000)", "0"},
+		{"let f = fn(n) { for (i in [1]) { if (n == 0) { return 0 }; return f(n - 1) } }; f(100000)", "0"},
+		{`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } };
+let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } };
+[even(100000), odd(100001)]`, "[true, true, ]"},
+		{"let f = fn(n, acc = 0) { if (n == 0) { acc } else { f(n - 1, acc + n) } }; f(100000)", "5000050000"},
+		{"let f = fn(n) { if (n == 0) { len([1, 2]) } else { f(n - 1) } }; f(100000)", "2"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(100)", "100"},
+		{"let f = fn(x) { x }; let g = fn() { f(1); f(2) }; g()", "2"},
+		{`let f = fn(n) { try { if (n == 0) { throw "bottom" }; return f(n - 1) } catch (e) { e.message } }; f(10)`, "bottom"},
+		{"let f = fn(n) { if (n == 0) { 1 + true } else { f(n - 1) } }; f(100000)", "ERROR: 1:33 type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(a) { a }; let g = fn() { f() }; g()", "ERROR: 1:37 too few arguments: want=1 got=0"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{}).Eval(program, object.NewEnvironment())
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+// TestTailCallsInMacros checks that a node spliced twice by a macro is not taken as a tail call at both places.
+func TestTailCallsInMacros(t *testing.T) {
+	input := `let twice = macro(x) { quote(fn() { unquote(x); unquote(x) }()) };
+let n = 0;
+let g = fn() { n += 1 };
+twice(g());
+n`
+	program := parser.New(lexer.New(input)).ParseProgram()
+	macros := object.NewEnvironment()
+	evaluator.DefineMacros(program, macros)
+	expanded, err := evaluator.ExpandMacros(program, macros)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testIntegerObject(t, evaluator.Eval(expanded, object.NewEnvironment()), 2)
+}
+
+func TestTailCallsErr(t *testing.T) {
+	cases := []struct {
+		input     string
+		wantErr   error
+		wantTrace string
+	}{
+		{"let g = fn() { 1 + true };\nlet f = fn() { g() };\nf()", evaluator.ErrTypeMismatch,
+			"\tat g (called at 2:16)\n\tat f (called at 3:1)\n"},
+		{"let h = fn() { 1 + true };\nlet g = fn() { h() };\nlet f = fn() { g() };\nf()", evaluator.ErrTypeMismatch,
+			"\tat h (called at 2:16)\n\tat f (called at 4:1)\n"},
+		{"let f = fn(n) { try { return f(n - 1) } catch (e) { 0 } };\nf(100000)", evaluator.ErrCallDepthExceeded, ""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{}).Eval(program, object.NewEnvironment())
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj, c.wantErr) {
+				t.Errorf("wrong error want=%v got=%v", c.wantErr, errObj)
+			}
+			if c.wantTrace != "" && errObj.StackTrace() != c.wantTrace {
+				t.Errorf("wrong stack trace want=%q got=%q", c.wantTrace, errObj.StackTrace())
+			}
+		})
+	}
+}
+
+// benchmarkRecursion runs f(depth) and reports the largest stack in use at the bottom of the recursion.
+func benchmarkRecursion(b *testing.B, f string, depths []int) {
+	for _, depth := range depths {
+		b.Run(fmt.Sprintf("depth=%d", depth), func(b *testing.B) {
+			program := parser.New(lexer.New(fmt.Sprintf("%s; f(%d)", f, depth))).ParseProgram()
+			var maxStack uint64
+			probe := &object.Builtin{Fn: func(args ...object.Object) object.Object {
+				var ms runtime.MemStats
+				runtime.ReadMemStats(&ms)
+				if ms.StackInuse > maxStack {
+					maxStack = ms.StackInuse
+				}
+				return &object.Integer{Value: 0}
+			}}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				env := object.NewEnvironment()
+				env.Set("probe", probe)
+				if ev := evaluator.New(evaluator.Options{}).Eval(program, env); ev.Inspect() != "0" {
+					b.Fatalf("unexpected result %s", ev.Inspect())
+				}
+			}
+			b.ReportMetric(float64(maxStack), "stack-bytes")
+		})
+	}
+}
+
+func BenchmarkTailRecursion(b *testing.B) {
+	benchmarkRecursion(b, "let f = fn(n) { if (n == 0) { probe() } else { f(n - 1) } }", []int{1000, 10000, 100000})
+}
+
+func BenchmarkNonTailRecursion(b *testing.B) {
+	benchmarkRecursion(b, "let f = fn(n) { if (n == 0) { probe() } else { 0 + f(n - 1) } }", []int{1000, 5000, 9000})
+}
@@ -0,0 +1,163 @@
+package evaluator
+
+import (
+	"errors"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/object"
+)
+
+// ErrThrown is the error of values thrown by throw statements with a kind other than a runtime error.
+var ErrThrown = errors.New("thrown")
+
+// DefaultErrorKind is the kind of errors made by error(msg) and of thrown values without a kind.
+const DefaultErrorKind = "Error"
+
+// errorKinds names the runtime errors for the kind of caught errors.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrTypeMismatch, "ErrTypeMismatch"},
+	{ErrUnknownOperator, "ErrUnknownOperator"},
+	{ErrIdentifierNotFound, "ErrIdentifierNotFound"},
+	{ErrIsNotFunction, "ErrIsNotFunction"},
+	{ErrIndexOperatorNotSupported, "ErrIndexOperatorNotSupported"},
+	{ErrUnusableAsHashKey, "ErrUnusableAsHashKey"},
+	{ErrDivisionByZero, "ErrDivisionByZero"},
+	{ErrIntegerOverflow, "ErrIntegerOverflow"},
+	{ErrIndexOutOfRange, "ErrIndexOutOfRange"},
+	{ErrNotIterable, "ErrNotIterable"},
+	{ErrTooManyArgs, "ErrTooManyArgs"},
+	{ErrTooFewArgs, "ErrTooFewArgs"},
+	{ErrTypeNotSupported, "ErrTypeNotSupported"},
+	{ErrArrayNeeded, "ErrArrayNeeded"},
+	{ErrHashNeeded, "ErrHashNeeded"},
+	{ErrFileOpenFailed, "ErrFileOpenFailed"},
+	{ErrInvalidArgument, "ErrInvalidArgument"},
+	{ErrModuleNotFound, "ErrModuleNotFound"},
+	{ErrImportFailed, "ErrImportFailed"},
+	{ErrImportCycle, "ErrImportCycle"},
+	{ErrNotExported, "ErrNotExported"},
+	{ErrNotQuote, "ErrNotQuote"},
+	{ErrCannotUnquote, "ErrCannotUnquote"},
+	{ErrMacroNotTopLevel, "ErrMacroNotTopLevel"},
+}
+
+// uncatchable errors stop the evaluation even in a try block so that the limits are kept.
+var uncatchable = []error{ErrStepLimit, ErrCallDepthExceeded, ErrAllocLimit, ErrEvaluationCanceled}
+
+// thrownError is the message of an error raised by a throw statement.
+type thrownError struct {
+	kind string
+	msg  string
+	err  error // the runtime error named kind, or ErrThrown
+}
+
+func (e *thrownError) Error() string {
+	if e.err != ErrThrown {
+		return e.msg // a rethrown runtime error keeps its message
+	}
+	return e.kind + ": " + e.msg
+}
+
+func (e *thrownError) Unwrap() error { return e.err }
+
+// evalTryStatement runs the catch block if the try block fails and then the finally block.
+// A return, break, continue or error in the finally block replaces the result.
+func (e *Evaluator) evalTryStatement(node *ast.TryStatement, env *object.Environment) object.Object {
+	result := e.Eval(node.Block, env)
+	if errObj, ok := result.(*object.Error); ok {
+		if !isCatchable(errObj) {
+			return errObj
+		}
+		if node.Catch != nil {
+			catchEnv := object.NewEnclosedEnvironment(env)
+			catchEnv.Set(node.Param.Value, errorValue(errObj))
+			result = e.Eval(node.Catch, catchEnv)
+		}
+	}
+	if node.Finally != nil {
+		switch fin := e.Eval(node.Finally, env); fin.(type) {
+		case *object.ReturnValue, *object.Error, *object.Break, *object.Continue:
+			return fin
+		}
+	}
+	return result
+}
+
+// evalThrowStatement raises val as an error.
+// Strings are messages, and Hashes give the "kind" and "message" like the values of error(msg).
+func (e *Evaluator) evalThrowStatement(node *ast.ThrowStatement, env *object.Environment) object.Object {
+	val := e.Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+	kind, msg := DefaultErrorKind, val.Inspect()
+	switch val := val.(type) {
+	case *object.String:
+		msg = val.Value
+	case *object.Hash:
+		if s, ok := hashString(val, "kind"); ok {
+			kind = s
+		}
+		if s, ok := hashString(val, "message"); ok {
+			msg = s
+		}
+	}
+	err := ErrThrown
+	for _, k := range errorKinds {
+		if k.kind == kind {
+			err = k.err
+		}
+	}
+	return &object.Error{Message: &thrownError{kind: kind, msg: msg, err: err}}
+}
+
+func isCatchable(errObj *object.Error) bool {
+	for _, err := range uncatchable {
+		if errors.Is(errObj, err) {
+			return false
+		}
+	}
+	return true
+}
+
+// errorValue converts errObj to a Hash with the kind, message, row and col.
+func errorValue(errObj *object.Error) *object.Hash {
+	kind, msg := DefaultErrorKind, errObj.Message.Error()
+	var thrown *thrownError
+	if errors.As(errObj, &thrown) {
+		kind, msg = thrown.kind, thrown.msg
+	} else {
+		for _, k := range errorKinds {
+			if errors.Is(errObj, k.err) {
+				kind = k.kind
+				break
+			}
+		}
+	}
+	h := newErrorHash(kind, msg)
+	h.Set(&object.String{Value: "row"}, &object.Integer{Value: int64(errObj.Row)})
+	h.Set(&object.String{Value: "col"}, &object.Integer{Value: int64(errObj.Col)})
+	return h
+}
+
+func newErrorHash(kind, msg string) *object.Hash {
+	h := object.NewHash()
+	h.Set(&object.String{Value: "kind"}, &object.String{Value: kind})
+	h.Set(&object.String{Value: "message"}, &object.String{Value: msg})
+	return h
+}
+
+func hashString(h *object.Hash, key string) (string, bool) {
+	v, ok := h.Get(&object.String{Value: key})
+	if !ok {
+		return "", false
+	}
+	s, ok := v.(*object.String)
+	if !ok {
+		return "", false
+	}
+	return s.Value, true
+}
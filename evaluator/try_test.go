@@ -0,0 +1,95 @@
+package evaluator_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestTryCatch(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { throw "boom"; 1 } catch (e) { e.message }`, "boom"},
+		{`try { throw "boom" } catch (e) { e }`, "{kind: Error, message: boom, row: 1, col: 7}"},
+		{`try { throw error("boom") } catch (e) { [e.kind, e.message] }`, "[Error, boom, ]"},
+		{`try { throw {"kind": "ValueError", "message": "bad"} } catch (e) { [e.kind, e.message] }`, "[ValueError, bad, ]"},
+		{`try { throw 42 } catch (e) { e.message }`, "42"},
+		{"try { 1 + true } catch (e) { [e.kind, e.message, e.row, e.col] }",
+			"[ErrTypeMismatch, type mismatch: INTEGER + BOOLEAN, 1, 9, ]"},
+		{"try { foo } catch (e) { e.kind }", "ErrIdentifierNotFound"},
+		{"try { 1 / 0 } catch (e) { e.kind }", "ErrDivisionByZero"},
+		{"let f = fn() { -true }; try { f() } catch (e) { [e.kind, e.col] }", "[ErrUnknownOperator, 16, ]"},
+		{"try { len(1, 2) } catch (e) { e.kind }", "ErrTooManyArgs"},
+		{"let n = 0; try { n = 1 } finally { n += 10 }; n", "11"},
+		{"let n = 0; try { throw 1 } catch (e) { n = 1 } finally { n += 10 }; n", "11"},
+		{"let f = fn() { try { return 1 } finally { puts(\"\") } }; f()", "1"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", "2"},
+		{"let f = fn() { try { throw 1 } catch (e) { return 2 } }; f()", "2"},
+		{"let n = 0; for (i in range(5)) { try { if (i == 3) { break } } finally { n += 1 } }; n", "4"},
+		{"try { try { 1 + true } catch (e) { throw e } } catch (e) { [e.kind, e.message] }",
+			"[ErrTypeMismatch, type mismatch: INTEGER + BOOLEAN, ]"},
+		{"try { try { throw 1 } finally { throw 2 } } catch (e) { e.message }", "2"},
+		{`let e = "outer"; try { throw 1 } catch (e) { e }; e`, "outer"},
+		{`throw "boom"`, "ERROR: 1:1 Error: boom"},
+		{`throw {"kind": "ValueError", "message": "bad"}`, "ERROR: 1:1 ValueError: bad"},
+		{`try { throw 1 } finally { 2 }`, "ERROR: 1:7 Error: 1"},
+		{"try { 1 } catch (e) { throw e }", "1"},
+		{`try { throw 1 } catch (e) { 1 + true }`, "ERROR: 1:31 type mismatch: INTEGER + BOOLEAN"},
+		{"error(1)", "ERROR: 1:1 type not supported: error(INTEGER)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{}).Eval(program, object.NewEnvironment())
+			if ev.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, ev.Inspect())
+			}
+		})
+	}
+}
+
+func TestThrowErr(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{`throw "boom"`, evaluator.ErrThrown, "1:1 Error: boom"},
+		{`throw {"kind": "ErrTypeMismatch", "message": "type mismatch: custom"}`, evaluator.ErrTypeMismatch, "1:1 type mismatch: custom"},
+		{"try { 1 + true } catch (e) { throw e }", evaluator.ErrTypeMismatch, "1:30 type mismatch: INTEGER + BOOLEAN"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			program := parser.New(lexer.New(c.input)).ParseProgram()
+			ev := evaluator.New(evaluator.Options{}).Eval(program, object.NewEnvironment())
+			errObj, ok := ev.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned got=%T (%+v)", ev, ev)
+			}
+			if !errors.Is(errObj, c.wantErr) {
+				t.Errorf("wrong error want=%v got=%v", c.wantErr, errObj)
+			}
+			if errObj.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, errObj.Error())
+			}
+		})
+	}
+}
+
+func TestLimitsAreNotCaught(t *testing.T) {
+	input := "let n = 0; try { while (true) { n += 1 } } catch (e) { n = -1 } finally { n = -2 }"
+	program := parser.New(lexer.New(input)).ParseProgram()
+	ev := evaluator.New(evaluator.Options{MaxSteps: 100}).Eval(program, object.NewEnvironment())
+	if !errors.Is(ev.(*object.Error), evaluator.ErrStepLimit) {
+		t.Errorf("want=%v got=%v", evaluator.ErrStepLimit, ev.Inspect())
+	}
+}
@@ -0,0 +1,650 @@
+// Package format prints Monkey programs in the canonical style.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/token"
+)
+
+// Indent is the indentation of a block level.
+const Indent = "    "
+
+// Source formats src and keeps its comments.
+// Syntax errors are returned as parser.ErrorList.
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewWithComments(string(src)))
+	program := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	pr := newPrinter(string(src), p.Comments())
+	pr.program(program)
+	return pr.out.Bytes(), nil
+}
+
+// Node formats node without comments.
+func Node(node ast.Node) string {
+	pr := newPrinter("", nil)
+	switch node := node.(type) {
+	case *ast.Program:
+		pr.program(node)
+	case ast.Statement:
+		pr.statement(node)
+	case ast.Expression:
+		pr.expression(node)
+	}
+	return pr.out.String()
+}
+
+// Operator precedences as in the parser.
+const (
+	_ int = iota
+	lowest
+	assign
+	or
+	and
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	postfix // calls, index and member expressions, and literals
+)
+
+var precedences = map[string]int{
+	token.OR:       or,
+	token.AND:      and,
+	token.EQ:       equals,
+	token.NEQ:      equals,
+	token.LT:       lessGreater,
+	token.GT:       lessGreater,
+	token.LTE:      lessGreater,
+	token.GTE:      lessGreater,
+	token.PLUS:     sum,
+	token.MINUS:    sum,
+	token.ASTERISK: product,
+	token.SLASH:    product,
+	token.PERCENT:  product,
+}
+
+type pos struct{ row, col int }
+
+func posOf(tok token.Token) pos { return pos{tok.Row, tok.Col} }
+
+func (a pos) before(b pos) bool { return a.row < b.row || (a.row == b.row && a.col < b.col) }
+
+var (
+	startPos = pos{0, 0}
+	endPos   = pos{int(^uint(0) >> 1), 0}
+)
+
+type printer struct {
+	out         bytes.Buffer
+	indent      int
+	comments    []token.Token     // comments not printed yet, in source order
+	allComments []token.Token     // for finding blank lines
+	tokens      []token.Token     // other tokens in source order
+	closing     map[pos]pos       // the position of the closing bracket for each "{", "(" and "["
+	printed     map[ast.Node]bool // blocks known to be printed on one line
+	endRows     map[pos]int       // cache of lastTokenRow
+}
+
+func newPrinter(src string, comments []token.Token) *printer {
+	p := &printer{
+		comments:    append([]token.Token(nil), comments...),
+		allComments: comments,
+		closing:     make(map[pos]pos),
+		printed:     make(map[ast.Node]bool),
+		endRows:     make(map[pos]int),
+	}
+	if src == "" {
+		return p
+	}
+	l := lexer.NewWithComments(src)
+	var open []pos
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		switch tok.Type {
+		case token.COMMENT:
+			continue
+		case token.LBRACE, token.LPAREN, token.LBRACKET:
+			open = append(open, posOf(tok))
+		case token.RBRACE, token.RPAREN, token.RBRACKET:
+			if n := len(open); n > 0 {
+				p.closing[open[n-1]] = posOf(tok)
+				open = open[:n-1]
+			}
+		}
+		p.tokens = append(p.tokens, tok)
+	}
+	return p
+}
+
+func (p *printer) print(a ...interface{}) { fmt.Fprint(&p.out, a...) }
+
+func (p *printer) newline() {
+	p.out.WriteByte('\n')
+	p.out.WriteString(strings.Repeat(Indent, p.indent))
+}
+
+// breakLine starts a new line indented by extra levels more than the current one.
+func (p *printer) breakLine(extra int) {
+	p.out.Truncate(len(bytes.TrimRight(p.out.Bytes(), " ")))
+	p.indent += extra
+	p.newline()
+	p.indent -= extra
+}
+
+// endRowBefore returns the last row of the tokens and comments before at, or 0 if there are none.
+func (p *printer) endRowBefore(at pos, withComments bool) int {
+	row := 0
+	if i := sort.Search(len(p.tokens), func(i int) bool { return !posOf(p.tokens[i]).before(at) }); i > 0 {
+		row = p.tokens[i-1].Row
+	}
+	if !withComments {
+		return row
+	}
+	if i := sort.Search(len(p.allComments), func(i int) bool { return !posOf(p.allComments[i]).before(at) }); i > 0 {
+		c := p.allComments[i-1]
+		if r := c.Row + strings.Count(c.Literal, "\n"); r > row {
+			row = r
+		}
+	}
+	return row
+}
+
+// lastTokenRow returns the row of the last token before at, ignoring comments.
+func (p *printer) lastTokenRow(at pos) int {
+	if row, ok := p.endRows[at]; ok {
+		return row
+	}
+	row := p.endRowBefore(at, false)
+	p.endRows[at] = row
+	return row
+}
+
+// take removes the comments between from and to that match f and returns them.
+func (p *printer) take(from, to pos, f func(token.Token) bool) []token.Token {
+	var taken []token.Token
+	rest := p.comments[:0]
+	for _, c := range p.comments {
+		if from.before(posOf(c)) && posOf(c).before(to) && f(c) {
+			taken = append(taken, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	p.comments = rest
+	return taken
+}
+
+// inline prints the comments before at that are not printed yet, so that comments inside a statement
+// stay between the same tokens. A comment on its own line in the source starts a new line and a line
+// comment ends one, which is indented by extra levels. It reports whether it printed any comments,
+// in which case the output ends with a space or a new line.
+func (p *printer) inline(at pos, extra int) bool {
+	cs := p.take(startPos, at, func(token.Token) bool { return true })
+	broken := false
+	for _, c := range cs {
+		out := p.out.Bytes()
+		switch {
+		case !broken && c.Row > p.lastTokenRow(posOf(c)):
+			p.breakLine(extra)
+		case len(out) > 0 && !bytes.ContainsAny(out[len(out)-1:], " ([{"):
+			p.print(" ")
+		}
+		lit := strings.TrimRight(c.Literal, " \t\r")
+		p.print(lit)
+		broken = strings.HasPrefix(lit, "//") || strings.Contains(lit, "\n")
+		if broken {
+			p.breakLine(extra)
+		} else {
+			p.print(" ")
+		}
+	}
+	return len(cs) > 0
+}
+
+// beforeClosing prints the comments before the bracket that closes open.
+func (p *printer) beforeClosing(open token.Token) {
+	if to, ok := p.closing[posOf(open)]; ok {
+		p.beforeSeparator(to)
+	}
+}
+
+// beforeSeparator prints the comments before at, where a "," or a closing bracket follows without a space.
+func (p *printer) beforeSeparator(at pos) {
+	if p.inline(at, 1) && bytes.HasSuffix(p.out.Bytes(), []byte("*/ ")) {
+		p.out.Truncate(p.out.Len() - 1)
+	}
+}
+
+// comma prints the "," before the element that starts at next and the comments before the ",".
+func (p *printer) comma(next pos) {
+	i := sort.Search(len(p.tokens), func(i int) bool { return !posOf(p.tokens[i]).before(next) })
+	for i--; i >= 0; i-- {
+		if p.tokens[i].Type == token.COMMA {
+			p.beforeSeparator(posOf(p.tokens[i]))
+			break
+		}
+	}
+	p.print(", ")
+}
+
+// startOf returns the position of the first token of expr, which is not its token for operators.
+func startOf(expr ast.Expression) pos {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return startOf(expr.Left)
+	case *ast.AssignExpression:
+		return startOf(expr.Target)
+	case *ast.CallExpression:
+		return startOf(expr.Function)
+	case *ast.IndexExpression:
+		return startOf(expr.Left)
+	case *ast.MemberExpression:
+		return startOf(expr.Object)
+	}
+	row, col := expr.Pos()
+	return pos{row, col}
+}
+
+func (p *printer) program(program *ast.Program) {
+	p.statements(program.Statements, startPos, endPos)
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+}
+
+// statements prints stmts and the comments between from and to, each on its own line.
+// The output starts at the current line and ends without a newline.
+func (p *printer) statements(stmts []ast.Statement, from, to pos) {
+	first := true
+	item := func(at pos) {
+		if !first {
+			if p.blankLineBefore(at) {
+				p.out.WriteByte('\n')
+			}
+			p.newline()
+		}
+		first = false
+	}
+	flush := func(until pos) {
+		for _, c := range p.take(from, until, func(token.Token) bool { return true }) {
+			item(posOf(c))
+			p.print(strings.TrimRight(c.Literal, " \t\r"))
+		}
+	}
+	for i, stmt := range stmts {
+		row, col := stmt.Pos()
+		start := pos{row, col}
+		flush(start)
+		item(start)
+		p.statement(stmt)
+		next := to
+		if i+1 < len(stmts) {
+			r, c := stmts[i+1].Pos()
+			next = pos{r, c}
+		}
+		// a comment on the last line of the statement
+		endRow, trailing := p.lastTokenRow(next), false
+		for _, c := range p.take(start, next, func(c token.Token) bool {
+			ok := !trailing && c.Row == endRow && !strings.Contains(c.Literal, "\n")
+			trailing = trailing || ok
+			return ok
+		}) {
+			p.print(" ", strings.TrimRight(c.Literal, " \t\r"))
+		}
+	}
+	flush(to)
+}
+
+// blankLineBefore reports whether the source has a blank line before the statement or comment at at.
+func (p *printer) blankLineBefore(at pos) bool {
+	if len(p.tokens) == 0 {
+		return false
+	}
+	prev := p.endRowBefore(at, true)
+	return prev > 0 && at.row-prev >= 2
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.letStatement(stmt)
+	case *ast.ExportStatement:
+		p.print("export ")
+		p.letStatement(stmt.Statement)
+	case *ast.ReturnStatement:
+		p.print("return ")
+		p.expression(stmt.ReturnValue)
+		p.print(";")
+	case *ast.ExpressionStatement:
+		if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+			p.ifExpression(ie, true)
+			return
+		}
+		p.expression(stmt.Expression)
+		p.print(";")
+	case *ast.WhileStatement:
+		p.print("while (")
+		p.expression(stmt.Condition)
+		p.print(") ")
+		p.block(stmt.Body)
+	case *ast.ForStatement:
+		p.print("for (", stmt.Variable.Value, " in ")
+		p.expression(stmt.Iterable)
+		p.print(") ")
+		p.block(stmt.Body)
+	case *ast.BreakStatement, *ast.ContinueStatement:
+		p.print(stmt.TokenLiteral(), ";")
+	case *ast.TryStatement:
+		p.print("try ")
+		p.block(stmt.Block)
+		if stmt.Catch != nil {
+			p.clause("catch", posOf(stmt.Param.Token))
+			p.print(" (", stmt.Param.Value, ") ")
+			p.block(stmt.Catch)
+		}
+		if stmt.Finally != nil {
+			p.clause("finally", posOf(stmt.Finally.Token))
+			p.print(" ")
+			p.block(stmt.Finally)
+		}
+	case *ast.ThrowStatement:
+		p.print("throw ")
+		p.expression(stmt.Value)
+		p.print(";")
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+// letStatement prints functions bound by let on multiple lines.
+func (p *printer) letStatement(stmt *ast.LetStatement) {
+	p.print("let ", stmt.Name.Value, " = ")
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		p.functionLiteral(fn.Token.Literal, fn.Parameters, fn.Defaults, fn.Rest, fn.Body, true)
+	} else {
+		p.expression(stmt.Value)
+	}
+	p.print(";")
+}
+
+// clause prints keyword after "}" and the comments before at, which follow the keyword in the source.
+func (p *printer) clause(keyword string, at pos) {
+	if !p.inline(at, 0) {
+		p.print(" ")
+	}
+	p.print(keyword)
+}
+
+// block prints "{", the statements on their own lines and "}".
+func (p *printer) block(b *ast.BlockStatement) {
+	from := posOf(b.Token)
+	p.inline(from, 1)
+	to, ok := p.closing[from]
+	if !ok {
+		to = from // not from the source
+	}
+	if len(b.Statements) == 0 && !p.hasComments(from, to) {
+		p.print("{}")
+		return
+	}
+	p.print("{")
+	p.indent++
+	p.newline()
+	p.statements(b.Statements, from, to)
+	p.indent--
+	p.newline()
+	p.print("}")
+}
+
+// inlineBlock prints b as "{ expr }" if it is an expression without comments that fits on a line.
+func (p *printer) inlineBlock(b *ast.BlockStatement) bool {
+	if !p.canInline(b) {
+		return false
+	}
+	p.inline(posOf(b.Token), 1)
+	p.print("{ ")
+	p.expression(b.Statements[0].(*ast.ExpressionStatement).Expression)
+	p.print(" }")
+	return true
+}
+
+func (p *printer) canInline(b *ast.BlockStatement) bool {
+	if inline, ok := p.printed[b]; ok {
+		return inline
+	}
+	inline := false
+	if len(b.Statements) == 1 {
+		if es, ok := b.Statements[0].(*ast.ExpressionStatement); ok {
+			from := posOf(b.Token)
+			to, ok := p.closing[from]
+			if !ok {
+				to = from
+			}
+			if !p.hasComments(from, to) {
+				// the block has no comments, so printing it to a scratch buffer does not consume any
+				sub := &printer{closing: p.closing, printed: p.printed, endRows: p.endRows}
+				sub.expression(es.Expression)
+				inline = !bytes.ContainsRune(sub.out.Bytes(), '\n')
+			}
+		}
+	}
+	p.printed[b] = inline
+	return inline
+}
+
+func (p *printer) hasComments(from, to pos) bool {
+	for _, c := range p.comments {
+		if from.before(posOf(c)) && posOf(c).before(to) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) ifExpression(ie *ast.IfExpression, multiline bool) {
+	p.print("if (")
+	p.expression(ie.Condition)
+	p.print(") ")
+	if !multiline && p.canInline(ie.Consequence) && (ie.Alternative == nil || p.canInline(ie.Alternative)) {
+		p.inlineBlock(ie.Consequence)
+		if ie.Alternative != nil {
+			p.clause("else", posOf(ie.Alternative.Token))
+			p.print(" ")
+			p.inlineBlock(ie.Alternative)
+		}
+		return
+	}
+	p.block(ie.Consequence)
+	if ie.Alternative != nil {
+		p.clause("else", posOf(ie.Alternative.Token))
+		p.print(" ")
+		p.block(ie.Alternative)
+	}
+}
+
+func (p *printer) functionLiteral(keyword string, params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier, body *ast.BlockStatement, multiline bool) {
+	p.print(keyword, "(")
+	for i, param := range params {
+		if i > 0 {
+			p.comma(posOf(param.Token))
+		}
+		p.inline(posOf(param.Token), 1)
+		p.print(param.Value)
+		if i < len(defaults) && defaults[i] != nil {
+			p.print(" = ")
+			p.expression(defaults[i])
+		}
+	}
+	if rest != nil {
+		if len(params) > 0 {
+			p.comma(posOf(rest.Token))
+		}
+		p.inline(posOf(rest.Token), 1)
+		p.print("...", rest.Value)
+	}
+	p.print(") ")
+	if multiline || !p.inlineBlock(body) {
+		p.block(body)
+	}
+}
+
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return precedences[expr.Operator]
+	case *ast.AssignExpression:
+		return assign
+	case *ast.PrefixExpression:
+		return prefix
+	default:
+		return postfix
+	}
+}
+
+// operand prints expr in parentheses if it binds looser than min.
+func (p *printer) operand(expr ast.Expression, min int) {
+	if precedence(expr) < min {
+		p.print("(")
+		p.expression(expr)
+		p.print(")")
+		return
+	}
+	p.expression(expr)
+}
+
+func (p *printer) expressions(exprs []ast.Expression) {
+	for i, expr := range exprs {
+		if i > 0 {
+			p.comma(startOf(expr))
+		}
+		p.expression(expr)
+	}
+}
+
+func (p *printer) expression(expr ast.Expression) {
+	switch expr.(type) {
+	case *ast.InfixExpression, *ast.AssignExpression, *ast.CallExpression, *ast.IndexExpression, *ast.MemberExpression:
+		// the comments before it are printed before its leftmost operand
+	default:
+		row, col := expr.Pos()
+		p.inline(pos{row, col}, 1)
+	}
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.print(expr.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.BooleanLiteral:
+		p.print(expr.TokenLiteral())
+	case *ast.StringLiteral:
+		p.print(Quote(expr.Value))
+	case *ast.PrefixExpression:
+		p.print(expr.Operator)
+		p.operand(expr.Right, prefix)
+	case *ast.InfixExpression:
+		prec := precedences[expr.Operator]
+		p.operand(expr.Left, prec)
+		p.print(" ", expr.Operator, " ")
+		p.operand(expr.Right, prec+1)
+	case *ast.AssignExpression:
+		p.operand(expr.Target, postfix)
+		p.print(" ", expr.Operator, " ")
+		p.operand(expr.Value, assign)
+	case *ast.IfExpression:
+		p.ifExpression(expr, false)
+	case *ast.FunctionLiteral:
+		p.functionLiteral(expr.Token.Literal, expr.Parameters, expr.Defaults, expr.Rest, expr.Body, false)
+	case *ast.MacroLiteral:
+		p.functionLiteral(expr.Token.Literal, expr.Parameters, nil, nil, expr.Body, false)
+	case *ast.CallExpression:
+		p.operand(expr.Function, postfix)
+		p.print("(")
+		p.expressions(expr.Arguments)
+		p.beforeClosing(expr.Token)
+		p.print(")")
+	case *ast.ArrayLiteral:
+		p.print("[")
+		p.expressions(expr.Elements)
+		p.beforeClosing(expr.Token)
+		p.print("]")
+	case *ast.IndexExpression:
+		p.operand(expr.Left, postfix)
+		p.print("[")
+		p.expression(expr.Index)
+		p.print("]")
+	case *ast.MemberExpression:
+		p.operand(expr.Object, postfix)
+		p.print(".", expr.Member.Value)
+	case *ast.ImportExpression:
+		p.print("import(")
+		p.expression(expr.Path)
+		p.print(")")
+	case *ast.HashLiteral:
+		p.print("{")
+		for i, pair := range expr.Pairs {
+			if i > 0 {
+				p.comma(startOf(pair.Key))
+			}
+			p.expression(pair.Key)
+			p.print(": ")
+			p.expression(pair.Value)
+		}
+		p.beforeClosing(expr.Token)
+		p.print("}")
+	}
+}
+
+// Quote returns s as a string literal with escape sequences for quotes, backslashes and unprintable characters.
+func Quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == utf8.RuneError && size == 1:
+			fmt.Fprintf(&sb, `\x%02x`, s[i-1])
+		case r == '"' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case escapes[r] != 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(escapes[r])
+		case unicode.IsPrint(r):
+			sb.WriteRune(r)
+		case r < utf8.RuneSelf:
+			fmt.Fprintf(&sb, `\x%02x`, r)
+		case r <= 0xffff:
+			fmt.Fprintf(&sb, `\u%04x`, r)
+		default:
+			fmt.Fprintf(&sb, `\U%08x`, r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+var escapes = map[rune]byte{
+	'\a': 'a',
+	'\b': 'b',
+	'\f': 'f',
+	'\n': 'n',
+	'\r': 'r',
+	'\t': 't',
+	'\v': 'v',
+	0:    '0',
+}
@@ -0,0 +1,165 @@
+package format_test
+
+import (
+	"errors"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/ebiiim/monkey/format"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/parser"
+)
+
+// checkFormat checks that the output of src parses to the same program and formats to itself.
+func checkFormat(t *testing.T, src string) string {
+	t.Helper()
+	p := parser.New(lexer.New(src))
+	want := p.ParseProgram().String()
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p = parser.New(lexer.New(string(out)))
+	got := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		t.Fatalf("output does not parse: %v\n%s", err, out)
+	}
+	if got.String() != want {
+		t.Errorf("different program want=%s got=%s\n%s", want, got.String(), out)
+	}
+	again, err := format.Source(out)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(again) != string(out) {
+		t.Errorf("not idempotent\nfirst:\n%s\nsecond:\n%s", out, again)
+	}
+	return string(out)
+}
+
+func TestSource(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"", ""},
+		{"let x=1", "let x = 1;\n"},
+		{"let add = fn(a, b) { a + b };", "let add = fn(a, b) {\n    a + b;\n};\n"},
+		{"map(arr, fn(x){x*2})", "map(arr, fn(x) { x * 2 });\n"},
+		{"fn(a, b = 1, ...c) { }", "fn(a, b = 1, ...c) {};\n"},
+		{"if(x){y}else{z}", "if (x) {\n    y;\n} else {\n    z;\n}\n"},
+		{"let v = if (x) { 1 } else { 2 };", "let v = if (x) { 1 } else { 2 };\n"},
+		{"1 + 2 * 3; (1 + 2) * 3; 1 - (2 - 3); (1 - 2) - 3", "1 + 2 * 3;\n(1 + 2) * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n"},
+		{"-(a + b); -a.b; (-a).b; !(a == b); - -a", "-(a + b);\n-a.b;\n(-a).b;\n!(a == b);\n--a;\n"},
+		{"a && b || c && d; a && (b || c)", "a && b || c && d;\na && (b || c);\n"},
+		{"x = y = 1; (x = 1) + 2; a[0] += 1", "x = y = 1;\n(x = 1) + 2;\na[0] += 1;\n"},
+		{"f(x)(y)[0].z; (a + b)(c)", "f(x)(y)[0].z;\n(a + b)(c);\n"},
+		{`{"a": 1, 2: [true, 1.50]}`, `{"a": 1, 2: [true, 1.50]};` + "\n"},
+		{`"tab\there \"q\" \\ \x00 é"`, `"tab\there \"q\" \\ \0 é";` + "\n"},
+		{`export let m = import("./m").f;`, `export let m = import("./m").f;` + "\n"},
+		{"while (x < 10) { x += 1; if (x == 5) { break } else { continue } }",
+			"while (x < 10) {\n    x += 1;\n    if (x == 5) {\n        break;\n    } else {\n        continue;\n    }\n}\n"},
+		{"for (x in xs) { puts(x) }", "for (x in xs) {\n    puts(x);\n}\n"},
+		{`try { throw error("x") } catch (e) { e } finally { done() }`,
+			"try {\n    throw error(\"x\");\n} catch (e) {\n    e;\n} finally {\n    done();\n}\n"},
+		{"let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) };",
+			"let unless = macro(c, x) { quote(if (!unquote(c)) { unquote(x) }) };\n"},
+		// comments and blank lines
+		{"// head\n\n\nlet x = 1; // x\nlet y = 2;\n\n/* block\n   comment */\nlet z = 3;\n// tail\n",
+			"// head\n\nlet x = 1; // x\nlet y = 2;\n\n/* block\n   comment */\nlet z = 3;\n// tail\n"},
+		{"let f = fn() { // start\n  let a = 1;   \n\n  a // a\n  // end\n}",
+			"let f = fn() {\n    // start\n    let a = 1;\n\n    a; // a\n    // end\n};\n"},
+		{"f(fn(x) { /* c */ x })", "f(fn(x) {\n    /* c */\n    x;\n});\n"},
+		{"let a = [1, // one\n 2];\nlet b = 3;", "let a = [1, // one\n    2];\nlet b = 3;\n"},
+		{"let a = [\n  // one\n  1,\n  2 // two\n];", "let a = [\n    // one\n    1, 2 // two\n    ];\n"},
+		{"f(/* a */ 1, {2: 3 /* b */})", "f(/* a */ 1, {2: 3 /* b */});\n"},
+		{"[1, 2 /* two */, 3]", "[1, 2 /* two */, 3];\n"},
+		{"puts(a[0] /* idx */)", "puts(a[0] /* idx */);\n"},
+		{"f(a, b // b\n, c)", "f(a, b // b\n    , c);\n"},
+		{"let f = fn(a /* a */, ...b) { a };", "let f = fn(a /* a */, ...b) {\n    a;\n};\n"},
+		{"if (x) { 1 } // one\nelse { 2 }", "if (x) {\n    1;\n} // one\nelse {\n    2;\n}\n"},
+		{"let v = if (x) { 1 } /* one */ else { 2 };", "let v = if (x) { 1 } /* one */ else { 2 };\n"},
+		{"try { f() } // f\ncatch (e) { e } /* e */ finally { g() }",
+			"try {\n    f();\n} // f\ncatch (e) {\n    e;\n} /* e */ finally {\n    g();\n}\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			if got := checkFormat(t, c.input); got != c.want {
+				t.Errorf("want=\n%s\ngot=\n%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestSourceErr(t *testing.T) {
+	_, err := format.Source([]byte("let = 1;"))
+	var errs parser.ErrorList
+	if !errors.As(err, &errs) || !errors.Is(err, parser.ErrTokenType) {
+		t.Errorf("want parser.ErrorList got=%v", err)
+	}
+}
+
+func TestPrelude(t *testing.T) {
+	src, err := ioutil.ReadFile("../evaluator/prelude.monkey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := checkFormat(t, string(src)); got != string(src) {
+		t.Errorf("prelude.monkey is not formatted\n%s", got)
+	}
+}
+
+// TestParserSnippets formats every string literal in the parser tests that is a valid program.
+func TestParserSnippets(t *testing.T) {
+	f, err := goparser.ParseFile(gotoken.NewFileSet(), "../parser/parser_test.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	goast.Inspect(f, func(node goast.Node) bool {
+		lit, ok := node.(*goast.BasicLit)
+		if !ok || lit.Kind != gotoken.STRING {
+			return true
+		}
+		src, err := strconv.Unquote(lit.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := parser.New(lexer.NewWithComments(src))
+		if program := p.ParseProgram(); p.Err() != nil || len(program.Statements) == 0 {
+			return true
+		}
+		n++
+		t.Run(src, func(t *testing.T) { checkFormat(t, src) })
+		return true
+	})
+	if n < 100 {
+		t.Errorf("too few snippets got=%d", n)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"abc", `"abc"`},
+		{"a\"b\\c", `"a\"b\\c"`},
+		{"\a\b\f\n\r\t\v\x00", `"\a\b\f\n\r\t\v\0"`},
+		{"\x01\x7f", `"\x01\x7f"`},
+		{"\xff", `"\xff"`},
+		{"é日本\u200b\U0001F600", "\"é日本\\u200b\U0001F600\""},
+	}
+	for _, c := range cases {
+		if got := format.Quote(c.input); got != c.want {
+			t.Errorf("want=%s got=%s", c.want, got)
+		}
+		p := parser.New(lexer.New(c.want))
+		if got := p.ParseProgram().String(); got != c.input {
+			t.Errorf("round trip want=%q got=%q", c.input, got)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+// Package interp embeds the Monkey interpreter in Go programs.
+package interp
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+// Errors
+var (
+	ErrUnsupportedType = errors.New("unsupported type")
+	ErrCannotConvert   = errors.New("cannot convert")
+	ErrNotFunc         = errors.New("not a func")
+	ErrHostFunc        = errors.New("host function failed")
+)
+
+// Func is a Monkey function converted to Go.
+type Func func(args ...interface{}) (interface{}, error)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Interpreter evaluates Monkey programs in a global environment that is kept between calls.
+type Interpreter struct {
+	ev     *evaluator.Evaluator
+	env    *object.Environment
+	macros *object.Environment
+}
+
+// New initializes an Interpreter.
+func New() *Interpreter {
+	return NewWithOptions(evaluator.Options{})
+}
+
+// NewWithOptions initializes an Interpreter with evaluator options.
+func NewWithOptions(opts evaluator.Options) *Interpreter {
+	ev := evaluator.New(opts)
+	return &Interpreter{ev: ev, env: ev.NewEnvironment(), macros: object.NewEnvironment()}
+}
+
+// Eval runs src and returns the value of the last statement converted by ToGo.
+// Syntax errors are returned as parser.ErrorList and runtime errors as *object.Error.
+func (it *Interpreter) Eval(src string) (interface{}, error) {
+	obj, err := it.EvalObject(src)
+	if err != nil {
+		return nil, err
+	}
+	return it.ToGo(obj)
+}
+
+// EvalObject is like Eval but returns the result without conversion.
+func (it *Interpreter) EvalObject(src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	evaluator.DefineMacros(program, it.macros)
+	expanded, err := it.ev.ExpandMacros(program, it.macros)
+	if err != nil {
+		return nil, err
+	}
+	obj := it.ev.Eval(expanded, it.env)
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+	return obj, nil
+}
+
+// Define binds value converted by FromGo to name in the global environment.
+func (it *Interpreter) Define(name string, value interface{}) error {
+	obj, err := it.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("define %s: %w", name, err)
+	}
+	it.env.Set(name, obj)
+	return nil
+}
+
+// RegisterFunc binds fn, which must be a Go func, to name in the global environment.
+// Arguments are converted to the parameter types of fn.
+// fn may return nothing, a value, an error, or a value and an error.
+func (it *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	if reflect.TypeOf(fn) == nil || reflect.TypeOf(fn).Kind() != reflect.Func {
+		return fmt.Errorf("register %s: %w: %T", name, ErrNotFunc, fn)
+	}
+	return it.Define(name, fn)
+}
+
+// Get returns the value bound to name converted by ToGo.
+func (it *Interpreter) Get(name string) (interface{}, bool, error) {
+	obj, ok := it.env.Get(name)
+	if !ok {
+		return nil, false, nil
+	}
+	v, err := it.ToGo(obj)
+	return v, true, err
+}
+
+// ToGo converts obj to a Go value:
+// Integer to int64, Float to float64, String to string, Boolean to bool, Null to nil,
+// Array to []interface{}, Hash to map[string]interface{} if all keys are strings
+// or map[interface{}]interface{} otherwise, and functions to Func.
+// Errors are returned as error.
+func (it *Interpreter) ToGo(obj object.Object) (interface{}, error) {
+	switch o := obj.(type) {
+	case nil, *object.Null:
+		return nil, nil
+	case *object.Integer:
+		return o.Value, nil
+	case *object.Float:
+		return o.Value, nil
+	case *object.String:
+		return o.Value, nil
+	case *object.Boolean:
+		return o.Value, nil
+	case *object.Array:
+		s := make([]interface{}, len(o.Elements))
+		for i, elem := range o.Elements {
+			v, err := it.ToGo(elem)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = v
+		}
+		return s, nil
+	case *object.Hash:
+		return it.hashToGo(o)
+	case *object.Function, *object.Builtin:
+		return it.funcToGo(o), nil
+	case *object.Error:
+		return nil, o
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, obj.Type())
+	}
+}
+
+func (it *Interpreter) hashToGo(hash *object.Hash) (interface{}, error) {
+	strKeys := make(map[string]interface{}, hash.Len())
+	anyKeys := make(map[interface{}]interface{}, hash.Len())
+	for _, pair := range hash.Pairs() {
+		k, err := it.ToGo(pair.Key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := it.ToGo(pair.Value)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := k.(string); ok {
+			strKeys[s] = v
+		}
+		anyKeys[k] = v
+	}
+	if len(strKeys) == len(anyKeys) {
+		return strKeys, nil
+	}
+	return anyKeys, nil
+}
+
+func (it *Interpreter) funcToGo(fn object.Object) Func {
+	return func(args ...interface{}) (interface{}, error) {
+		objs := make([]object.Object, len(args))
+		for i, arg := range args {
+			obj, err := it.FromGo(arg)
+			if err != nil {
+				return nil, err
+			}
+			objs[i] = obj
+		}
+		return it.ToGo(it.ev.Apply(fn, objs...))
+	}
+}
+
+// FromGo converts a Go value to an object:
+// integers to Integer, floats to Float, string to String, bool to Boolean, nil to Null,
+// slices and arrays to Array, maps to Hash, and funcs to Builtin.
+// An object.Object is returned as is.
+func (it *Interpreter) FromGo(v interface{}) (object.Object, error) {
+	if v == nil {
+		return evaluator.NULL, nil
+	}
+	return it.fromValue(reflect.ValueOf(v))
+}
+
+func (it *Interpreter) fromValue(rv reflect.Value) (object.Object, error) {
+	if rv.Type().Implements(objectType) {
+		if isNil(rv) {
+			return evaluator.NULL, nil
+		}
+		return rv.Interface().(object.Object), nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if rv.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("%w: %d overflows INTEGER", ErrCannotConvert, rv.Uint())
+		}
+		return &object.Integer{Value: int64(rv.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &object.Float{Value: rv.Float()}, nil
+	case reflect.String:
+		return &object.String{Value: rv.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elems := make([]object.Object, rv.Len())
+		for i := range elems {
+			elem, err := it.fromValue(rv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elems[i] = elem
+		}
+		return &object.Array{Elements: elems}, nil
+	case reflect.Map:
+		hash := object.NewHash()
+		for _, mk := range sortedKeys(rv) {
+			k, err := it.fromValue(mk)
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", evaluator.ErrUnusableAsHashKey, k.Type())
+			}
+			val, err := it.fromValue(rv.MapIndex(mk))
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key, val)
+		}
+		return hash, nil
+	case reflect.Interface, reflect.Ptr:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return it.fromValue(rv.Elem())
+	case reflect.Func:
+		if rv.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return &object.Builtin{Fn: it.wrapFunc(rv)}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedType, rv.Type())
+	}
+}
+
+// wrapFunc makes a builtin that converts its arguments, calls fn, and converts the results.
+func (it *Interpreter) wrapFunc(fn reflect.Value) object.BuiltinFunction {
+	ft := fn.Type()
+	return func(args ...object.Object) object.Object {
+		numIn := ft.NumIn()
+		if ft.IsVariadic() {
+			numIn--
+			if len(args) < numIn {
+				return newError(evaluator.ErrTooFewArgs, "want>=%d got=%d", numIn, len(args))
+			}
+		} else if len(args) < numIn {
+			return newError(evaluator.ErrTooFewArgs, "want=%d got=%d", numIn, len(args))
+		} else if len(args) > numIn {
+			return newError(evaluator.ErrTooManyArgs, "want=%d got=%d", numIn, len(args))
+		}
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var typ reflect.Type
+			if i < numIn {
+				typ = ft.In(i)
+			} else {
+				typ = ft.In(numIn).Elem()
+			}
+			v, err := it.toValue(arg, typ)
+			if err != nil {
+				return newError(err, "argument %d", i+1)
+			}
+			in[i] = v
+		}
+		out := fn.Call(in)
+		if n := len(out); n > 0 && ft.Out(n-1) == errorType {
+			if err, _ := out[n-1].Interface().(error); err != nil {
+				return newError(ErrHostFunc, "%v", err)
+			}
+			out = out[:n-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+		obj, err := it.fromValue(out[0])
+		if err != nil {
+			return newError(err, "result")
+		}
+		return obj
+	}
+}
+
+// toValue converts obj to a Go value of typ.
+func (it *Interpreter) toValue(obj object.Object, typ reflect.Type) (reflect.Value, error) {
+	if (typ.Kind() != reflect.Interface || typ.NumMethod() > 0) && reflect.TypeOf(obj).AssignableTo(typ) {
+		return reflect.ValueOf(obj), nil // object.Object, *object.Hash, etc.
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		v, err := it.ToGo(obj)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Zero(typ), nil
+		}
+		rv := reflect.ValueOf(v)
+		if !rv.Type().AssignableTo(typ) {
+			return reflect.Value{}, fmt.Errorf("%w: %s to %s", ErrCannotConvert, obj.Type(), typ)
+		}
+		return rv, nil
+	case reflect.Bool:
+		if b, ok := obj.(*object.Boolean); ok {
+			return reflect.ValueOf(b.Value).Convert(typ), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*object.Integer); ok {
+			rv := reflect.New(typ).Elem()
+			if rv.OverflowInt(i.Value) {
+				return reflect.Value{}, fmt.Errorf("%w: %d overflows %s", ErrCannotConvert, i.Value, typ)
+			}
+			rv.SetInt(i.Value)
+			return rv, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*object.Integer); ok {
+			rv := reflect.New(typ).Elem()
+			if i.Value < 0 || rv.OverflowUint(uint64(i.Value)) {
+				return reflect.Value{}, fmt.Errorf("%w: %d overflows %s", ErrCannotConvert, i.Value, typ)
+			}
+			rv.SetUint(uint64(i.Value))
+			return rv, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *object.Float:
+			return reflect.ValueOf(n.Value).Convert(typ), nil
+		case *object.Integer:
+			return reflect.ValueOf(float64(n.Value)).Convert(typ), nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*object.String); ok {
+			return reflect.ValueOf(s.Value).Convert(typ), nil
+		}
+	case reflect.Slice:
+		if arr, ok := obj.(*object.Array); ok {
+			rv := reflect.MakeSlice(typ, len(arr.Elements), len(arr.Elements))
+			for i, elem := range arr.Elements {
+				v, err := it.toValue(elem, typ.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				rv.Index(i).Set(v)
+			}
+			return rv, nil
+		}
+	case reflect.Map:
+		if hash, ok := obj.(*object.Hash); ok {
+			rv := reflect.MakeMapWithSize(typ, hash.Len())
+			for _, pair := range hash.Pairs() {
+				k, err := it.toValue(pair.Key, typ.Key())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				v, err := it.toValue(pair.Value, typ.Elem())
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				rv.SetMapIndex(k, v)
+			}
+			return rv, nil
+		}
+	case reflect.Func:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			return it.makeFunc(obj, typ), nil
+		}
+	}
+	if obj == evaluator.NULL {
+		switch typ.Kind() {
+		case reflect.Slice, reflect.Map, reflect.Func, reflect.Ptr:
+			return reflect.Zero(typ), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("%w: %s to %s", ErrCannotConvert, obj.Type(), typ)
+}
+
+// makeFunc makes a Go func of typ that calls the Monkey function fn.
+// If typ returns an error as the last result, runtime and conversion errors are returned there; otherwise they panic.
+func (it *Interpreter) makeFunc(fn object.Object, typ reflect.Type) reflect.Value {
+	return reflect.MakeFunc(typ, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, typ.NumOut())
+		for i := range out {
+			out[i] = reflect.Zero(typ.Out(i))
+		}
+		fail := func(err error) []reflect.Value {
+			if n := len(out); n > 0 && typ.Out(n-1) == errorType {
+				out[n-1] = reflect.ValueOf(&err).Elem()
+				return out
+			}
+			panic(err)
+		}
+		var vs []reflect.Value
+		for i, v := range in {
+			if typ.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < v.Len(); j++ {
+					vs = append(vs, v.Index(j))
+				}
+				continue
+			}
+			vs = append(vs, v)
+		}
+		args := make([]object.Object, len(vs))
+		for i, v := range vs {
+			arg, err := it.fromValue(v)
+			if err != nil {
+				return fail(err)
+			}
+			args[i] = arg
+		}
+		result := it.ev.Apply(fn, args...)
+		if errObj, ok := result.(*object.Error); ok {
+			return fail(errObj)
+		}
+		if len(out) > 0 && typ.Out(0) != errorType {
+			v, err := it.toValue(result, typ.Out(0))
+			if err != nil {
+				return fail(err)
+			}
+			out[0] = v
+		}
+		return out
+	})
+}
+
+// sortedKeys returns the keys of a map sorted if they are strings or integers.
+func sortedKeys(m reflect.Value) []reflect.Value {
+	keys := m.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		ki, kj := keys[i], keys[j]
+		switch ki.Kind() {
+		case reflect.String:
+			return ki.String() < kj.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return ki.Int() < kj.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return ki.Uint() < kj.Uint()
+		}
+		return false
+	})
+	return keys
+}
+
+func isNil(rv reflect.Value) bool {
+	switch rv.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return rv.IsNil()
+	}
+	return false
+}
+
+func newError(errType error, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Errorf("%w: %s", errType, fmt.Sprintf(format, a...))}
+}
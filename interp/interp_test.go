@@ -0,0 +1,260 @@
+package interp_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/interp"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+)
+
+func TestEval(t *testing.T) {
+	cases := []struct {
+		input string
+		want  interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{`"foo" + "bar"`, "foobar"},
+		{"1 < 2", true},
+		{"100 * 0.15", 15.0},
+		{"if (false) { 1 }", nil},
+		{"[1, true, [\"a\"]]", []interface{}{int64(1), true, []interface{}{"a"}}},
+		{`{"a": 1, "b": [2]}`, map[string]interface{}{"a": int64(1), "b": []interface{}{int64(2)}}},
+		{`{1: "a", true: "b"}`, map[interface{}]interface{}{int64(1): "a", true: "b"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			got, err := interp.New().Eval(c.input)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want=%#v got=%#v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestMacros(t *testing.T) {
+	it := interp.New()
+	if _, err := it.Eval("let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got, err := it.Eval(`unless(1 > 2, "yes", 1 + true)`)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got != "yes" {
+		t.Errorf("want=yes got=%#v", got)
+	}
+	_, err = it.Eval("unless(true)")
+	if !errors.Is(err, evaluator.ErrTooFewArgs) {
+		t.Errorf("want %v got=%v", evaluator.ErrTooFewArgs, err)
+	}
+}
+
+func TestEvalErr(t *testing.T) {
+	it := interp.New()
+	_, err := it.Eval("let a = ;")
+	var parseErrs parser.ErrorList
+	if !errors.As(err, &parseErrs) || !errors.Is(err, parser.ErrNoParseFunc) {
+		t.Errorf("want parser.ErrorList got=%v", err)
+	}
+	_, err = it.Eval("1 + true")
+	var errObj *object.Error
+	if !errors.As(err, &errObj) || !errors.Is(err, evaluator.ErrTypeMismatch) {
+		t.Errorf("want *object.Error got=%v", err)
+	}
+}
+
+func TestDefine(t *testing.T) {
+	type myInt int
+	cases := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"nil", nil, "null"},
+		{"int", 1, "1"},
+		{"named int", myInt(-2), "-2"},
+		{"uint8", uint8(3), "3"},
+		{"float32", float32(0.5), "0.5"},
+		{"float64", 2.0, "2.0"},
+		{"string", "foo", "foo"},
+		{"bool", true, "true"},
+		{"slice", []string{"a", "b"}, "[a, b, ]"},
+		{"array", [2]int{1, 2}, "[1, 2, ]"},
+		{"map", map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{"int map", map[int]bool{2: true, 1: false}, "{1: false, 2: true}"},
+		{"pointer", new(int), "0"},
+		{"object", &object.Integer{Value: 5}, "5"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			it := interp.New()
+			if err := it.Define("x", c.value); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			obj, err := it.EvalObject("x")
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if obj.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, obj.Inspect())
+			}
+		})
+	}
+}
+
+func TestDefineErr(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   interface{}
+		wantErr error
+	}{
+		{"struct", struct{}{}, interp.ErrUnsupportedType},
+		{"chan", make(chan int), interp.ErrUnsupportedType},
+		{"uint overflow", uint64(1 << 63), interp.ErrCannotConvert},
+		{"slice key", map[[1]int]int{{1}: 1}, evaluator.ErrUnusableAsHashKey},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := interp.New().Define("x", c.value)
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, err)
+			}
+		})
+	}
+	if err := interp.New().RegisterFunc("f", 1); !errors.Is(err, interp.ErrNotFunc) {
+		t.Errorf("want=%v got=%v", interp.ErrNotFunc, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	cases := []struct {
+		name  string
+		fn    interface{}
+		input string
+		want  string
+	}{
+		{"no result", func() {}, "f()", "null"},
+		{"int", func(a, b int) int { return a + b }, "f(1, 2)", "3"},
+		{"string", strings.ToUpper, `f("abc")`, "ABC"},
+		{"variadic", func(sep string, ss ...string) string { return strings.Join(ss, sep) }, `f("-", "a", "b")`, "a-b"},
+		{"variadic empty", func(ss ...string) int { return len(ss) }, `f()`, "0"},
+		{"slice", func(a []int64) int { return len(a) }, "f([1, 2, 3])", "3"},
+		{"float", func(price float64, qty int) float64 { return price * float64(qty) }, "f(1.5, 2)", "3.0"},
+		{"int as float", func(v float32) float32 { return v / 2 }, "f(3)", "1.5"},
+		{"map", func(m map[string]int) int { return m["a"] * m["b"] }, `f({"a": 2, "b": 3})`, "6"},
+		{"interface", func(v interface{}) string { return fmt.Sprintf("%T", v) }, `f([1])`, "[]interface {}"},
+		{"null", func(v interface{}, s []int) bool { return v == nil && s == nil }, `f(if (false) { 1 }, if (false) { 1 })`, "true"},
+		{"object", func(h *object.Hash) int { return h.Len() }, `f({1: 2})`, "1"},
+		{"value and nil error", func() (int, error) { return 1, nil }, "f()", "1"},
+		{"callback", func(f func(int) int) int { return f(20) + 1 }, "f(fn(x) { x * 2 })", "41"},
+		{"callback with error", func(f func(int) (int, error)) string {
+			_, err := f(1)
+			return err.Error()
+		}, "f(fn(x) { x + true })", "1:13 type mismatch: INTEGER + BOOLEAN"},
+		{"callback builtin", func(f func([]int) int) int { return f([]int{1, 2}) }, "f(len)", "2"},
+		{"callback with unconvertible argument", func(f func(chan int) error) bool {
+			return errors.Is(f(make(chan int)), interp.ErrUnsupportedType)
+		}, "f(fn(c) { c })", "true"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			it := interp.New()
+			if err := it.RegisterFunc("f", c.fn); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			obj, err := it.EvalObject(c.input)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if obj.Inspect() != c.want {
+				t.Errorf("want=%s got=%s", c.want, obj.Inspect())
+			}
+		})
+	}
+}
+
+func TestRegisterFuncErr(t *testing.T) {
+	errFoo := errors.New("foo")
+	cases := []struct {
+		name    string
+		fn      interface{}
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"too few", func(a, b int) {}, "f(1)", evaluator.ErrTooFewArgs, "1:1 too few arguments: want=2 got=1"},
+		{"too many", func(a int) {}, "f(1, 2)", evaluator.ErrTooManyArgs, "1:1 too many arguments: want=1 got=2"},
+		{"variadic too few", func(a int, b ...int) {}, "f()", evaluator.ErrTooFewArgs, "1:1 too few arguments: want>=1 got=0"},
+		{"type", func(a int) {}, `f("a")`, interp.ErrCannotConvert, "1:1 cannot convert: STRING to int: argument 1"},
+		{"overflow", func(a int8) {}, `f(128)`, interp.ErrCannotConvert, "1:1 cannot convert: 128 overflows int8: argument 1"},
+		{"negative uint", func(a uint) {}, `f(-1)`, interp.ErrCannotConvert, "1:1 cannot convert: -1 overflows uint: argument 1"},
+		{"error", func() error { return errFoo }, "f()", interp.ErrHostFunc, "1:1 host function failed: foo"},
+		{"value and error", func() (int, error) { return 0, errFoo }, "\n  f()", interp.ErrHostFunc, "2:3 host function failed: foo"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			it := interp.New()
+			if err := it.RegisterFunc("f", c.fn); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			_, err := it.Eval(c.input)
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("want=%v got=%v", c.wantErr, err)
+			}
+			if err.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestFuncToGo(t *testing.T) {
+	it := interp.New()
+	v, err := it.Eval("fn(a, b) { a + b }")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f, ok := v.(interp.Func)
+	if !ok {
+		t.Fatalf("want interp.Func got=%T", v)
+	}
+	got, err := f(1, 2)
+	if err != nil || got != int64(3) {
+		t.Errorf("want=3 got=%v err=%v", got, err)
+	}
+	if _, err := f(1); !errors.Is(err, evaluator.ErrTooFewArgs) {
+		t.Errorf("want=%v got=%v", evaluator.ErrTooFewArgs, err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	it := interp.New()
+	if _, err := it.Eval(`let config = {"port": 8080, "hosts": ["a", "b"]};`); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	v, ok, err := it.Get("config")
+	if !ok || err != nil {
+		t.Fatalf("want ok got=%v err=%v", ok, err)
+	}
+	want := map[string]interface{}{"port": int64(8080), "hosts": []interface{}{"a", "b"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("want=%#v got=%#v", want, v)
+	}
+	if _, ok, _ := it.Get("nothing"); ok {
+		t.Errorf("want !ok")
+	}
+}
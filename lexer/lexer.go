@@ -1,25 +1,56 @@
 package lexer
 
 import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/ebiiim/monkey/token"
 )
 
-var defaultTabSize = 4
+// Errors
+var (
+	ErrUnterminatedComment = errors.New("ErrUnterminatedComment")
+	ErrUnterminatedString  = errors.New("ErrUnterminatedString")
+	ErrInvalidEscape       = errors.New("ErrInvalidEscape")
+	ErrInvalidNumber       = errors.New("ErrInvalidNumber")
+)
+
+// Error is an error found from Row and Col up to EndRow and EndCol (exclusive).
+type Error struct {
+	Row, Col       int
+	EndRow, EndCol int
+	Kind           error  // one of the errors above
+	Message        string // without the position and the kind
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d:%d %s (%v)", e.Row, e.Col, e.Message, e.Kind)
+}
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+// TabSize is the number of columns a tab advances in token positions.
+const TabSize = 4
 
 // Lexer represents a lexer.
 type Lexer struct {
 	input                  string
 	position, readPosition int
-	ch                     byte
+	ch                     rune
 	row, col               int
 	tabSize                int
+	keepComments           bool
+	errs                   []error
 }
 
 // New initializes a lexer.
 func New(input string) *Lexer {
 	l := &Lexer{
 		input:   input,
-		tabSize: defaultTabSize,
+		tabSize: TabSize,
 		row:     1,
 		col:     0,
 	}
@@ -27,23 +58,37 @@ func New(input string) *Lexer {
 	return l
 }
 
+// NewWithComments initializes a lexer that emits token.COMMENT instead of skipping comments.
+func NewWithComments(input string) *Lexer {
+	l := New(input)
+	l.keepComments = true
+	return l
+}
+
+// Errors returns errors found so far. They are *Error.
+func (l *Lexer) Errors() []error {
+	return l.errs
+}
+
+// Pos returns the position of the next character, which is the end of the last token read.
+func (l *Lexer) Pos() (row, col int) {
+	return l.row, l.col
+}
+
+// addError records an error of kind from row and col up to the current position.
+func (l *Lexer) addError(kind error, row, col int, format string, a ...interface{}) {
+	l.errs = append(l.errs, &Error{Row: row, Col: col, EndRow: l.row, EndCol: l.col, Kind: kind, Message: fmt.Sprintf(format, a...)})
+}
+
 // NextToken reads the next token.
 func (l *Lexer) NextToken() token.Token {
-	// skip non-token characters
-	skip := true
-	for skip {
-		switch l.ch {
-		case ' ', '\r':
-			l.readChar()
-		case '\t':
-			l.col += l.tabSize - 1
-			l.readChar()
-		case '\n':
-			l.col = 0
-			l.row++
-			l.readChar()
-		default:
-			skip = false
+	for {
+		l.skipWhitespace()
+		if l.ch != '/' || (l.peekChar() != '/' && l.peekChar() != '*') {
+			break
+		}
+		if tok := l.readComment(); l.keepComments {
+			return tok
 		}
 	}
 
@@ -57,8 +102,16 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case '+':
 		tok = token.NewC(token.PLUS, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.PLUS_ASSIGN, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
 	case '-':
 		tok = token.NewC(token.MINUS, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.MINUS_ASSIGN, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
 	case '!':
 		tok = token.NewC(token.BANG, l.ch, l.row, l.col)
 		if nc := l.peekChar(); nc == '=' {
@@ -67,16 +120,55 @@ func (l *Lexer) NextToken() token.Token {
 		}
 	case '*':
 		tok = token.NewC(token.ASTERISK, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.ASTERISK_ASSIGN, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
 	case '/':
 		tok = token.NewC(token.SLASH, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.SLASH_ASSIGN, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
+	case '%':
+		tok = token.NewC(token.PERCENT, l.ch, l.row, l.col)
 	case '<':
 		tok = token.NewC(token.LT, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.LTE, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
 	case '>':
 		tok = token.NewC(token.GT, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '=' {
+			tok = token.New(token.GTE, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
+	case '&':
+		tok = token.NewC(token.ILLEGAL, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '&' {
+			tok = token.New(token.AND, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
+	case '|':
+		tok = token.NewC(token.ILLEGAL, l.ch, l.row, l.col)
+		if nc := l.peekChar(); nc == '|' {
+			tok = token.New(token.OR, string(l.ch)+string(nc), l.row, l.col)
+			l.readChar()
+		}
 	case ',':
 		tok = token.NewC(token.COMMA, l.ch, l.row, l.col)
 	case ';':
 		tok = token.NewC(token.SEMICOLON, l.ch, l.row, l.col)
+	case ':':
+		tok = token.NewC(token.COLON, l.ch, l.row, l.col)
+	case '.':
+		tok = token.NewC(token.DOT, l.ch, l.row, l.col)
+		if l.peekChar() == '.' && l.peekCharN(2) == '.' {
+			tok = token.New(token.ELLIPSIS, "...", l.row, l.col)
+			l.readChar()
+			l.readChar()
+		}
 	case '(':
 		tok = token.NewC(token.LPAREN, l.ch, l.row, l.col)
 	case ')':
@@ -90,8 +182,7 @@ func (l *Lexer) NextToken() token.Token {
 	case ']':
 		tok = token.NewC(token.RBRACKET, l.ch, l.row, l.col)
 	case '"':
-		tok = token.New(token.STRING, "", l.row, l.col)
-		tok.Literal = l.readString()
+		return l.readString()
 	case 0:
 		tok = token.New(token.EOF, "", l.row, l.col)
 	default:
@@ -101,7 +192,8 @@ func (l *Lexer) NextToken() token.Token {
 			return tokenNewIdent(t, lit, l.row, l.col)
 		}
 		if isDigit(l.ch) {
-			return tokenNewIdent(token.INT, l.readNumber(), l.row, l.col)
+			lit, t := l.readNumber()
+			return tokenNewIdent(t, lit, l.row, l.col)
 		}
 		tok = token.NewC(token.ILLEGAL, l.ch, l.row, l.col)
 	}
@@ -111,19 +203,66 @@ func (l *Lexer) NextToken() token.Token {
 
 // tokenNewIdent does token.New and set token.Token.Col to the first charactor of the given s.
 func tokenNewIdent(t token.Type, s string, row, col int) token.Token {
-	return token.New(t, s, row, col-len(s))
+	return token.New(t, s, row, col-utf8.RuneCountInString(s))
 }
 
+func (l *Lexer) skipWhitespace() {
+	for l.ch == ' ' || l.ch == '\r' || l.ch == '\t' || l.ch == '\n' {
+		l.consumeChar()
+	}
+}
+
+// consumeChar reads the next character keeping the row and the column of tabs and newlines.
+func (l *Lexer) consumeChar() {
+	switch l.ch {
+	case '\t':
+		l.col += l.tabSize - 1
+	case '\n':
+		l.col = 0
+		l.row++
+	}
+	l.readChar()
+}
+
+// readComment reads "// line" or "/* block */" comment.
+func (l *Lexer) readComment() token.Token {
+	tok := token.New(token.COMMENT, "", l.row, l.col)
+	position := l.position
+	if l.peekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.consumeChar()
+		}
+		tok.Literal = l.input[position:l.position]
+		return tok
+	}
+	l.consumeChar() // skip '/'
+	l.consumeChar() // skip '*'
+	for !(l.ch == '*' && l.peekChar() == '/') {
+		if l.ch == 0 {
+			l.addError(ErrUnterminatedComment, tok.Row, tok.Col, "unterminated comment")
+			tok.Literal = l.input[position:l.position]
+			return tok
+		}
+		l.consumeChar()
+	}
+	l.consumeChar() // skip '*'
+	l.consumeChar() // skip '/'
+	tok.Literal = l.input[position:l.position]
+	return tok
+}
+
+// readChar reads the next UTF-8 encoded character. Invalid bytes are read as utf8.RuneError.
 func (l *Lexer) readChar() {
 	l.col++
+	size := 0
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, size = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += size
 }
 
 func (l *Lexer) readIdentifier() string {
@@ -134,36 +273,165 @@ func (l *Lexer) readIdentifier() string {
 	return l.input[position:l.position]
 }
 
-func (l *Lexer) readString() string {
-	position := l.readPosition
-	for {
+// escapes maps characters following a backslash to the characters they represent.
+var escapes = map[rune]rune{
+	'a':  '\a',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// hexEscapes maps escape characters that take hex digits to the number of digits.
+var hexEscapes = map[rune]int{
+	'x': 2, // a byte
+	'u': 4,
+	'U': 8,
+}
+
+// readString reads a string literal and decodes escape sequences.
+// It returns a token.ILLEGAL holding the raw literal if the string is unterminated or has invalid escapes.
+func (l *Lexer) readString() token.Token {
+	tok := token.New(token.STRING, "", l.row, l.col)
+	position := l.position
+	var sb strings.Builder
+	valid := true
+	l.consumeChar() // skip '"'
+	for l.ch != '"' {
+		switch l.ch {
+		case 0:
+			l.addError(ErrUnterminatedString, tok.Row, tok.Col, "unterminated string")
+			return token.New(token.ILLEGAL, l.input[position:l.position], tok.Row, tok.Col)
+		case '\\':
+			valid = l.readEscape(&sb) && valid
+		default:
+			sb.WriteRune(l.ch)
+			l.consumeChar()
+		}
+	}
+	l.readChar() // skip '"'
+	if !valid {
+		return token.New(token.ILLEGAL, l.input[position:l.position], tok.Row, tok.Col)
+	}
+	tok.Literal = sb.String()
+	return tok
+}
+
+// readEscape reads an escape sequence like "\n", "\x41", "\u00e9" or "\U0001F600" and writes the character.
+func (l *Lexer) readEscape(sb *strings.Builder) bool {
+	row, col, position := l.row, l.col, l.position
+	l.readChar() // skip '\\'
+	if r, ok := escapes[l.ch]; ok {
+		sb.WriteRune(r)
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		return true
+	}
+	n, ok := hexEscapes[l.ch]
+	if !ok {
+		if l.ch == 0 {
+			return false // reported as an unterminated string
 		}
+		l.consumeChar()
+		l.addError(ErrInvalidEscape, row, col, "invalid escape sequence %s", l.input[position:l.position])
+		return false
 	}
-	return l.input[position:l.position]
+	kind := l.ch
+	l.readChar()
+	var v rune
+	for i := 0; i < n; i++ {
+		d, ok := hexValue(l.ch)
+		if !ok {
+			l.addError(ErrInvalidEscape, row, col, "invalid escape sequence %s", l.input[position:l.position])
+			return false
+		}
+		v = v*16 + d
+		l.readChar()
+	}
+	switch {
+	case kind == 'x':
+		sb.WriteByte(byte(v))
+	case utf8.ValidRune(v):
+		sb.WriteRune(v)
+	default:
+		l.addError(ErrInvalidEscape, row, col, "invalid escape sequence %s", l.input[position:l.position])
+		return false
+	}
+	return true
 }
 
-func isLetter(ch byte) bool {
-	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_'
+func hexValue(ch rune) (rune, bool) {
+	switch {
+	case '0' <= ch && ch <= '9':
+		return ch - '0', true
+	case 'a' <= ch && ch <= 'f':
+		return ch - 'a' + 10, true
+	case 'A' <= ch && ch <= 'F':
+		return ch - 'A' + 10, true
+	default:
+		return 0, false
+	}
 }
 
-func (l *Lexer) readNumber() string {
-	position := l.position
+func isLetter(ch rune) bool {
+	return ('a' <= ch && ch <= 'z') || ('A' <= ch && ch <= 'Z') || ch == '_' || (ch >= utf8.RuneSelf && unicode.IsLetter(ch))
+}
+
+// readNumber reads an integer like "12" or a float like "1.5", "1e-3" or "1.5E+3".
+// An exponent without digits like "1e" or "1.5e-" is reported and read as token.ILLEGAL.
+func (l *Lexer) readNumber() (string, token.Type) {
+	position, row, col := l.position, l.row, l.col
+	t := token.Type(token.INT)
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		t = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		if !isDigit(l.ch) {
+			l.addError(ErrInvalidNumber, row, col, "exponent has no digits in %s", l.input[position:l.position])
+			return l.input[position:l.position], token.ILLEGAL
+		}
+		t = token.FLOAT
+		l.readDigits()
+	}
+	return l.input[position:l.position], t
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
-	return l.input[position:l.position]
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func (l *Lexer) peekChar() rune {
+	return l.peekCharN(1)
+}
+
+// peekCharN returns the n-th character after the current one.
+func (l *Lexer) peekCharN(n int) rune {
+	position := l.readPosition
+	for ; n > 1 && position < len(l.input); n-- {
+		_, size := utf8.DecodeRuneInString(l.input[position:])
+		position += size
+	}
+	if position >= len(l.input) {
 		return 0
 	}
-	return l.input[l.readPosition]
+	r, _ := utf8.DecodeRuneInString(l.input[position:])
+	return r
 }
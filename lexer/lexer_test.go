@@ -1,6 +1,7 @@
 package lexer_test
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/ebiiim/monkey/lexer"
@@ -78,14 +79,14 @@ let result = add(five, ten);`, []token.Token{
 
 			token.New(token.EOF, "", 8, 29),
 		}},
-		{"section1.4#1", `!-/*5;
-5 < 10 > 5;`, []token.Token{
+		{"section1.4#1", `!-/ *5;
+5 < 10 > 5;`, []token.Token{ // "/*" starts a comment
 			token.New(token.BANG, "!", 1, 1),
 			token.New(token.MINUS, "-", 1, 2),
 			token.New(token.SLASH, "/", 1, 3),
-			token.New(token.ASTERISK, "*", 1, 4),
-			token.New(token.INT, "5", 1, 5),
-			token.New(token.SEMICOLON, ";", 1, 6),
+			token.New(token.ASTERISK, "*", 1, 5),
+			token.New(token.INT, "5", 1, 6),
+			token.New(token.SEMICOLON, ";", 1, 7),
 			token.New(token.INT, "5", 2, 1),
 			token.New(token.LT, "<", 2, 3),
 			token.New(token.INT, "10", 2, 5),
@@ -153,6 +154,115 @@ let result = add(five, ten);`, []token.Token{
 			token.New(token.SEMICOLON, ";", 1, 7),
 			token.New(token.EOF, "", 1, 8),
 		}},
+		{"ellipsis", `fn(a, ...b) .. .`, []token.Token{
+			token.New(token.FUNCTION, "fn", 1, 1),
+			token.New(token.LPAREN, "(", 1, 3),
+			token.New(token.IDENT, "a", 1, 4),
+			token.New(token.COMMA, ",", 1, 5),
+			token.New(token.ELLIPSIS, "...", 1, 7),
+			token.New(token.IDENT, "b", 1, 10),
+			token.New(token.RPAREN, ")", 1, 11),
+			token.New(token.DOT, ".", 1, 13),
+			token.New(token.DOT, ".", 1, 14),
+			token.New(token.DOT, ".", 1, 16),
+			token.New(token.EOF, "", 1, 17),
+		}},
+		{"comments", `// line comment
+let a = 1; // trailing
+/* block
+	comment */ a / /**/ 2;
+//`, []token.Token{
+			token.New(token.LET, "let", 2, 1),
+			token.New(token.IDENT, "a", 2, 5),
+			token.New(token.ASSIGN, "=", 2, 7),
+			token.New(token.INT, "1", 2, 9),
+			token.New(token.SEMICOLON, ";", 2, 10),
+			token.New(token.IDENT, "a", 4, 16),
+			token.New(token.SLASH, "/", 4, 18),
+			token.New(token.INT, "2", 4, 25),
+			token.New(token.SEMICOLON, ";", 4, 26),
+			token.New(token.EOF, "", 5, 3),
+		}},
+		{"numbers", `1 1.5 0.15 1e3 2.5E-3 1e+2 1. 1.e 1e a.5 3...`, []token.Token{
+			token.New(token.INT, "1", 1, 1),
+			token.New(token.FLOAT, "1.5", 1, 3),
+			token.New(token.FLOAT, "0.15", 1, 7),
+			token.New(token.FLOAT, "1e3", 1, 12),
+			token.New(token.FLOAT, "2.5E-3", 1, 16),
+			token.New(token.FLOAT, "1e+2", 1, 23),
+			token.New(token.INT, "1", 1, 28),
+			token.New(token.DOT, ".", 1, 29),
+			token.New(token.INT, "1", 1, 31),
+			token.New(token.DOT, ".", 1, 32),
+			token.New(token.IDENT, "e", 1, 33),
+			token.New(token.ILLEGAL, "1e", 1, 35),
+			token.New(token.IDENT, "a", 1, 38),
+			token.New(token.DOT, ".", 1, 39),
+			token.New(token.INT, "5", 1, 40),
+			token.New(token.INT, "3", 1, 42),
+			token.New(token.ELLIPSIS, "...", 1, 43),
+			token.New(token.EOF, "", 1, 46),
+		}},
+		{"loop keywords", `while for in break continue`, []token.Token{
+			token.New(token.WHILE, "while", 1, 1),
+			token.New(token.FOR, "for", 1, 7),
+			token.New(token.IN, "in", 1, 11),
+			token.New(token.BREAK, "break", 1, 14),
+			token.New(token.CONTINUE, "continue", 1, 20),
+			token.New(token.EOF, "", 1, 28),
+		}},
+		{"compound assignment", `+= -= *= /= / =`, []token.Token{
+			token.New(token.PLUS_ASSIGN, "+=", 1, 1),
+			token.New(token.MINUS_ASSIGN, "-=", 1, 4),
+			token.New(token.ASTERISK_ASSIGN, "*=", 1, 7),
+			token.New(token.SLASH_ASSIGN, "/=", 1, 10),
+			token.New(token.SLASH, "/", 1, 13),
+			token.New(token.ASSIGN, "=", 1, 15),
+			token.New(token.EOF, "", 1, 16),
+		}},
+		{"comparison and logical operators", `<= >= % && || & | < >`, []token.Token{
+			token.New(token.LTE, "<=", 1, 1),
+			token.New(token.GTE, ">=", 1, 4),
+			token.New(token.PERCENT, "%", 1, 7),
+			token.New(token.AND, "&&", 1, 9),
+			token.New(token.OR, "||", 1, 12),
+			token.New(token.ILLEGAL, "&", 1, 15),
+			token.New(token.ILLEGAL, "|", 1, 17),
+			token.New(token.LT, "<", 1, 19),
+			token.New(token.GT, ">", 1, 21),
+			token.New(token.EOF, "", 1, 22),
+		}},
+		{"escapes", `"a\tb\n" "\"q\" \\ \'" "\x41\u00e9\U0001F600" "\0"`, []token.Token{
+			token.New(token.STRING, "a\tb\n", 1, 1),
+			token.New(token.STRING, "\"q\" \\ '", 1, 10),
+			token.New(token.STRING, "A\u00e9\U0001F600", 1, 24),
+			token.New(token.STRING, "\x00", 1, 47),
+			token.New(token.EOF, "", 1, 51),
+		}},
+		{"unicode", "let café = \"日本語\";\nπ + é", []token.Token{
+			token.New(token.LET, "let", 1, 1),
+			token.New(token.IDENT, "café", 1, 5),
+			token.New(token.ASSIGN, "=", 1, 10),
+			token.New(token.STRING, "日本語", 1, 12),
+			token.New(token.SEMICOLON, ";", 1, 17),
+			token.New(token.IDENT, "π", 2, 1),
+			token.New(token.PLUS, "+", 2, 3),
+			token.New(token.IDENT, "é", 2, 5),
+			token.New(token.EOF, "", 2, 6),
+		}},
+		{"multi-line string", "\"a\nb\" c", []token.Token{
+			token.New(token.STRING, "a\nb", 1, 1),
+			token.New(token.IDENT, "c", 2, 4),
+			token.New(token.EOF, "", 2, 5),
+		}},
+		{"section4.6#1", `{"foo": "bar"}`, []token.Token{
+			token.New(token.LBRACE, "{", 1, 1),
+			token.New(token.STRING, "foo", 1, 2),
+			token.New(token.COLON, ":", 1, 7),
+			token.New(token.STRING, "bar", 1, 9),
+			token.New(token.RBRACE, "}", 1, 14),
+			token.New(token.EOF, "", 1, 15),
+		}},
 	}
 	for _, c := range cases {
 		c := c
@@ -176,3 +286,195 @@ let result = add(five, ten);`, []token.Token{
 		})
 	}
 }
+
+func TestNextTokenWithComments(t *testing.T) {
+	input := `// line comment
+let a = 1; // trailing
+/* block
+	comment */ a;`
+	want := []token.Token{
+		token.New(token.COMMENT, "// line comment", 1, 1),
+		token.New(token.LET, "let", 2, 1),
+		token.New(token.IDENT, "a", 2, 5),
+		token.New(token.ASSIGN, "=", 2, 7),
+		token.New(token.INT, "1", 2, 9),
+		token.New(token.SEMICOLON, ";", 2, 10),
+		token.New(token.COMMENT, "// trailing", 2, 12),
+		token.New(token.COMMENT, "/* block\n\tcomment */", 3, 1),
+		token.New(token.IDENT, "a", 4, 16),
+		token.New(token.SEMICOLON, ";", 4, 17),
+		token.New(token.EOF, "", 4, 18),
+	}
+	l := lexer.NewWithComments(input)
+	for i, wt := range want {
+		tok := l.NextToken()
+		if tok != wt {
+			t.Fatalf("token#%d: want=%+v got=%+v", i, wt, tok)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Fatalf("unexpected errors %v", l.Errors())
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	input := `let a = 1;
+  /* oops
+a;`
+	l := lexer.New(input)
+	var tok token.Token
+	for tok.Type != token.EOF {
+		tok = l.NextToken()
+	}
+	if len(l.Errors()) != 1 {
+		t.Fatalf("len(l.Errors()) want=1 got=%d", len(l.Errors()))
+	}
+	err := l.Errors()[0]
+	if !errors.Is(err, lexer.ErrUnterminatedComment) {
+		t.Errorf("wrong error type want=%v got=%v", lexer.ErrUnterminatedComment, err)
+	}
+	if want := "2:3 unterminated comment (ErrUnterminatedComment)"; err.Error() != want {
+		t.Errorf("wrong error message want=%s got=%s", want, err)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	cases := []struct {
+		input    string
+		wantToks []token.Token
+		wantErrs []string
+	}{
+		{`"abc`, []token.Token{
+			token.New(token.ILLEGAL, `"abc`, 1, 1),
+			token.New(token.EOF, "", 1, 5),
+		}, []string{"1:1 unterminated string (ErrUnterminatedString)"}},
+		{`a "x\q\u12" b`, []token.Token{
+			token.New(token.IDENT, "a", 1, 1),
+			token.New(token.ILLEGAL, `"x\q\u12"`, 1, 3),
+			token.New(token.IDENT, "b", 1, 13),
+			token.New(token.EOF, "", 1, 14),
+		}, []string{
+			"1:5 invalid escape sequence \\q (ErrInvalidEscape)",
+			"1:7 invalid escape sequence \\u12 (ErrInvalidEscape)",
+		}},
+		{`"\UFFFFFFFF"`, []token.Token{
+			token.New(token.ILLEGAL, `"\UFFFFFFFF"`, 1, 1),
+			token.New(token.EOF, "", 1, 13),
+		}, []string{"1:2 invalid escape sequence \\UFFFFFFFF (ErrInvalidEscape)"}},
+		{`"é\`, []token.Token{
+			token.New(token.ILLEGAL, `"é\`, 1, 1),
+			token.New(token.EOF, "", 1, 4),
+		}, []string{"1:1 unterminated string (ErrUnterminatedString)"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			l := lexer.New(c.input)
+			for i, wt := range c.wantToks {
+				if tok := l.NextToken(); tok != wt {
+					t.Fatalf("token#%d: want=%+v got=%+v", i, wt, tok)
+				}
+			}
+			if len(l.Errors()) != len(c.wantErrs) {
+				t.Fatalf("len(l.Errors()) want=%d got=%d (%v)", len(c.wantErrs), len(l.Errors()), l.Errors())
+			}
+			for i, err := range l.Errors() {
+				if err.Error() != c.wantErrs[i] {
+					t.Errorf("error#%d want=%s got=%s", i, c.wantErrs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestNumberErrors(t *testing.T) {
+	cases := []struct {
+		input    string
+		wantToks []token.Token
+		wantErrs []string
+	}{
+		{"1e", []token.Token{
+			token.New(token.ILLEGAL, "1e", 1, 1),
+			token.New(token.EOF, "", 1, 3),
+		}, []string{"1:1 exponent has no digits in 1e (ErrInvalidNumber)"}},
+		{"1e+;", []token.Token{
+			token.New(token.ILLEGAL, "1e+", 1, 1),
+			token.New(token.SEMICOLON, ";", 1, 4),
+			token.New(token.EOF, "", 1, 5),
+		}, []string{"1:1 exponent has no digits in 1e+ (ErrInvalidNumber)"}},
+		{"x = 1.5e- 2", []token.Token{
+			token.New(token.IDENT, "x", 1, 1),
+			token.New(token.ASSIGN, "=", 1, 3),
+			token.New(token.ILLEGAL, "1.5e-", 1, 5),
+			token.New(token.INT, "2", 1, 11),
+			token.New(token.EOF, "", 1, 12),
+		}, []string{"1:5 exponent has no digits in 1.5e- (ErrInvalidNumber)"}},
+		{"1E5 2Ex", []token.Token{
+			token.New(token.FLOAT, "1E5", 1, 1),
+			token.New(token.ILLEGAL, "2E", 1, 5),
+			token.New(token.IDENT, "x", 1, 7),
+			token.New(token.EOF, "", 1, 8),
+		}, []string{"1:5 exponent has no digits in 2E (ErrInvalidNumber)"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			l := lexer.New(c.input)
+			for i, wt := range c.wantToks {
+				if tok := l.NextToken(); tok != wt {
+					t.Fatalf("token#%d: want=%+v got=%+v", i, wt, tok)
+				}
+			}
+			if len(l.Errors()) != len(c.wantErrs) {
+				t.Fatalf("len(l.Errors()) want=%d got=%d (%v)", len(c.wantErrs), len(l.Errors()), l.Errors())
+			}
+			for i, err := range l.Errors() {
+				if err.Error() != c.wantErrs[i] {
+					t.Errorf("error#%d want=%s got=%s", i, c.wantErrs[i], err)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorSpan(t *testing.T) {
+	cases := []struct {
+		input string
+		want  lexer.Error
+	}{
+		{`"a\qb"`, lexer.Error{Row: 1, Col: 3, EndRow: 1, EndCol: 5, Kind: lexer.ErrInvalidEscape, Message: `invalid escape sequence \q`}},
+		{`"\u12x"`, lexer.Error{Row: 1, Col: 2, EndRow: 1, EndCol: 6, Kind: lexer.ErrInvalidEscape, Message: `invalid escape sequence \u12`}},
+		{"x\n  \"ab", lexer.Error{Row: 2, Col: 3, EndRow: 2, EndCol: 6, Kind: lexer.ErrUnterminatedString, Message: "unterminated string"}},
+		{"x = 1.5e+", lexer.Error{Row: 1, Col: 5, EndRow: 1, EndCol: 10, Kind: lexer.ErrInvalidNumber, Message: "exponent has no digits in 1.5e+"}},
+		{"/* a\nb", lexer.Error{Row: 1, Col: 1, EndRow: 2, EndCol: 2, Kind: lexer.ErrUnterminatedComment, Message: "unterminated comment"}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			l := lexer.New(c.input)
+			for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			}
+			if len(l.Errors()) != 1 {
+				t.Fatalf("want 1 error got=%v", l.Errors())
+			}
+			var err *lexer.Error
+			if !errors.As(l.Errors()[0], &err) {
+				t.Fatalf("want *lexer.Error got=%T", l.Errors()[0])
+			}
+			if *err != c.want {
+				t.Errorf("want=%+v got=%+v", c.want, *err)
+			}
+		})
+	}
+}
+
+func TestPos(t *testing.T) {
+	l := lexer.New("let s = \"a\\tb\";\n\tx")
+	want := [][2]int{{1, 4}, {1, 6}, {1, 8}, {1, 15}, {1, 16}, {2, 6}}
+	for i, w := range want {
+		tok := l.NextToken()
+		if row, col := l.Pos(); row != w[0] || col != w[1] {
+			t.Errorf("token#%d %q: end want=%d:%d got=%d:%d", i, tok.Literal, w[0], w[1], row, col)
+		}
+	}
+}
@@ -0,0 +1,602 @@
+package lsp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/format"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/token"
+)
+
+// pos is a position as in tokens: a 1-based row and a 1-based column where a tab takes lexer.TabSize columns.
+type pos struct{ row, col int }
+
+func posOf(tok token.Token) pos { return pos{tok.Row, tok.Col} }
+
+func (a pos) before(b pos) bool { return a.row < b.row || (a.row == b.row && a.col < b.col) }
+
+var endPos = pos{int(^uint(0) >> 1), 0}
+
+type defKind int
+
+const (
+	defLet defKind = iota
+	defParam
+	defRest
+	defFor
+	defCatch
+)
+
+// definition is a name bound by a let statement, a parameter, a for statement or a catch.
+type definition struct {
+	name    *ast.Identifier
+	kind    defKind
+	value   ast.Expression // the value of a let, or the default value of a parameter
+	from    pos            // where the name is bound in its own scope
+	prelude bool
+}
+
+// scope is the part of the source where the definitions in it are visible.
+// Functions, macros, for statements and catch blocks make scopes as they do when evaluated.
+type scope struct {
+	parent     *scope
+	start, end pos
+	defs       []*definition
+	children   []*scope
+}
+
+// ident is an identifier in the source that refers to or defines a name.
+type ident struct {
+	node  *ast.Identifier
+	scope *scope
+	def   *definition // set if the identifier is the name of a definition
+}
+
+// document is an open text document and the results of analyzing it.
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	program     *ast.Program
+	diagnostics []Diagnostic
+	tokens      []token.Token
+	closing     map[pos]pos // the position of the closing bracket for each opening one
+	root        *scope
+	idents      []*ident // in source order
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{
+		uri:     uri,
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+		closing: make(map[pos]pos),
+	}
+	d.lex()
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	for _, diag := range p.Diagnostics() {
+		d.diagnostics = append(d.diagnostics, Diagnostic{
+			Range:    d.rangeOf(pos{diag.Start.Row, diag.Start.Col}, pos{diag.End.Row, diag.End.Col}),
+			Severity: int(diag.Severity),
+			Code:     diag.Code,
+			Source:   "monkey",
+			Message:  diag.Message,
+		})
+	}
+	d.root = &scope{parent: preludeScope(), end: endPos}
+	d.walk(d.program, d.root)
+	return d
+}
+
+func (d *document) lex() {
+	l := lexer.New(d.text)
+	var open []pos
+	for {
+		tok := l.NextToken()
+		if tok.Type == token.EOF {
+			break
+		}
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			open = append(open, posOf(tok))
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			if n := len(open); n > 0 {
+				d.closing[open[n-1]] = posOf(tok)
+				open = open[:n-1]
+			}
+		}
+		d.tokens = append(d.tokens, tok)
+	}
+}
+
+// endOf returns the position after tok.
+func (d *document) endOf(tok token.Token) pos {
+	if tok.Type != token.STRING {
+		return pos{tok.Row, tok.Col + utf8.RuneCountInString(tok.Literal)}
+	}
+	// the literal of a string is unescaped so find the closing quote in the source
+	start := posOf(tok)
+	p, escaped := d.next(start, '"'), false
+	for _, r := range d.text[d.offset(start)+1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return d.next(p, r)
+		}
+		p = d.next(p, r)
+	}
+	return p
+}
+
+// next returns the position after r at p.
+func (d *document) next(p pos, r rune) pos {
+	switch r {
+	case '\n':
+		return pos{p.row + 1, 1}
+	case '\t':
+		return pos{p.row, p.col + lexer.TabSize}
+	}
+	return pos{p.row, p.col + 1}
+}
+
+// offset returns the byte offset of p in the text.
+func (d *document) offset(p pos) int {
+	off := 0
+	for i := 0; i < p.row-1 && i < len(d.lines); i++ {
+		off += len(d.lines[i]) + 1
+	}
+	if p.row-1 >= len(d.lines) {
+		return len(d.text)
+	}
+	col := 1
+	for i, r := range d.lines[p.row-1] {
+		if col >= p.col {
+			return off + i
+		}
+		col = d.next(pos{p.row, col}, r).col
+	}
+	return off + len(d.lines[p.row-1])
+}
+
+// position converts p to a protocol position counting UTF-16 code units.
+func (d *document) position(p pos) Position {
+	if p.row-1 >= len(d.lines) {
+		last := len(d.lines) - 1
+		return Position{Line: last, Character: utf16Len(d.lines[last])}
+	}
+	line, col, char := d.lines[p.row-1], 1, 0
+	for _, r := range line {
+		if col >= p.col {
+			break
+		}
+		col = d.next(pos{p.row, col}, r).col
+		char += utf16RuneLen(r)
+	}
+	return Position{Line: p.row - 1, Character: char}
+}
+
+// pos converts a protocol position to the position in tokens.
+func (d *document) pos(p Position) pos {
+	if p.Line >= len(d.lines) {
+		return endPos
+	}
+	col, char := 1, 0
+	for _, r := range d.lines[p.Line] {
+		if char >= p.Character {
+			break
+		}
+		col = d.next(pos{p.Line + 1, col}, r).col
+		char += utf16RuneLen(r)
+	}
+	return pos{p.Line + 1, col}
+}
+
+func (d *document) rangeOf(from, to pos) Range {
+	return Range{Start: d.position(from), End: d.position(to)}
+}
+
+func (d *document) identRange(id *ast.Identifier) Range {
+	return d.rangeOf(posOf(id.Token), d.endOf(id.Token))
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+// isNil reports whether n is nil or an interface holding a nil pointer, as the parser leaves for statements it failed to parse.
+func isNil(n interface{}) bool {
+	if n == nil {
+		return true
+	}
+	v := reflect.ValueOf(n)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// blockEnd returns the position of the "}" of block, or the end of the source if it is missing.
+func (d *document) blockEnd(block *ast.BlockStatement) pos {
+	if isNil(block) {
+		return endPos
+	}
+	if end, ok := d.closing[posOf(block.Token)]; ok {
+		return end
+	}
+	return endPos
+}
+
+func (d *document) newScope(parent *scope, start pos, end pos) *scope {
+	sc := &scope{parent: parent, start: start, end: end}
+	parent.children = append(parent.children, sc)
+	return sc
+}
+
+func (d *document) define(sc *scope, name *ast.Identifier, kind defKind, value ast.Expression) {
+	if isNil(name) {
+		return
+	}
+	def := &definition{name: name, kind: kind, value: value, from: posOf(name.Token)}
+	if kind == defLet && !isNil(value) {
+		def.from = d.nodeEnd(value)
+	}
+	sc.defs = append(sc.defs, def)
+	d.idents = append(d.idents, &ident{node: name, scope: sc, def: def})
+}
+
+// walk finds the scopes, definitions and references in node.
+func (d *document) walk(node ast.Node, sc *scope) {
+	if isNil(node) {
+		return
+	}
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, stmt := range node.Statements {
+			d.walk(stmt, sc)
+		}
+	case *ast.BlockStatement:
+		for _, stmt := range node.Statements {
+			d.walk(stmt, sc)
+		}
+	case *ast.LetStatement:
+		// the name is visible in the value so that functions can call themselves
+		d.define(sc, node.Name, defLet, node.Value)
+		d.walk(node.Value, sc)
+	case *ast.ExportStatement:
+		d.walk(node.Statement, sc)
+	case *ast.ReturnStatement:
+		d.walk(node.ReturnValue, sc)
+	case *ast.ExpressionStatement:
+		d.walk(node.Expression, sc)
+	case *ast.ThrowStatement:
+		d.walk(node.Value, sc)
+	case *ast.WhileStatement:
+		d.walk(node.Condition, sc)
+		d.walk(node.Body, sc)
+	case *ast.ForStatement:
+		d.walk(node.Iterable, sc)
+		inner := d.newScope(sc, posOf(node.Token), d.blockEnd(node.Body))
+		d.define(inner, node.Variable, defFor, nil)
+		d.walk(node.Body, inner)
+	case *ast.TryStatement:
+		d.walk(node.Block, sc)
+		if !isNil(node.Catch) && !isNil(node.Param) {
+			inner := d.newScope(sc, posOf(node.Param.Token), d.blockEnd(node.Catch))
+			d.define(inner, node.Param, defCatch, nil)
+			d.walk(node.Catch, inner)
+		}
+		d.walk(node.Finally, sc)
+	case *ast.FunctionLiteral:
+		inner := d.newScope(sc, posOf(node.Token), d.blockEnd(node.Body))
+		for i, param := range node.Parameters {
+			var def ast.Expression
+			if i < len(node.Defaults) && !isNil(node.Defaults[i]) {
+				def = node.Defaults[i]
+				d.walk(def, inner)
+			}
+			d.define(inner, param, defParam, def)
+		}
+		d.define(inner, node.Rest, defRest, nil)
+		d.walk(node.Body, inner)
+	case *ast.MacroLiteral:
+		inner := d.newScope(sc, posOf(node.Token), d.blockEnd(node.Body))
+		for _, param := range node.Parameters {
+			d.define(inner, param, defParam, nil)
+		}
+		d.walk(node.Body, inner)
+	case *ast.Identifier:
+		d.idents = append(d.idents, &ident{node: node, scope: sc})
+	case *ast.PrefixExpression:
+		d.walk(node.Right, sc)
+	case *ast.InfixExpression:
+		d.walk(node.Left, sc)
+		d.walk(node.Right, sc)
+	case *ast.AssignExpression:
+		d.walk(node.Target, sc)
+		d.walk(node.Value, sc)
+	case *ast.IfExpression:
+		d.walk(node.Condition, sc)
+		d.walk(node.Consequence, sc)
+		d.walk(node.Alternative, sc)
+	case *ast.CallExpression:
+		d.walk(node.Function, sc)
+		for _, arg := range node.Arguments {
+			d.walk(arg, sc)
+		}
+	case *ast.ArrayLiteral:
+		for _, elem := range node.Elements {
+			d.walk(elem, sc)
+		}
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			d.walk(pair.Key, sc)
+			d.walk(pair.Value, sc)
+		}
+	case *ast.IndexExpression:
+		d.walk(node.Left, sc)
+		d.walk(node.Index, sc)
+	case *ast.MemberExpression:
+		d.walk(node.Object, sc) // members are not names in scope
+	case *ast.ImportExpression:
+		d.walk(node.Path, sc)
+	}
+}
+
+// scopeAt returns the innermost scope containing p.
+func (d *document) scopeAt(p pos) *scope {
+	sc := d.root
+	for {
+		var inner *scope
+		for _, child := range sc.children {
+			if !p.before(child.start) && !child.end.before(p) {
+				inner = child
+			}
+		}
+		if inner == nil {
+			return sc
+		}
+		sc = inner
+	}
+}
+
+// identAt returns the identifier touching p.
+func (d *document) identAt(p pos) *ident {
+	for _, id := range d.idents {
+		start, end := posOf(id.node.Token), d.endOf(id.node.Token)
+		if !p.before(start) && !end.before(p) {
+			return id
+		}
+	}
+	return nil
+}
+
+// resolve finds the definition of name visible from sc at p.
+// A scope prefers the last definition before p so that rebound names resolve to the binding in effect,
+// and otherwise gives the first one so that functions can call functions defined after them.
+// A let binds its name after its value, but functions in the value already see it so that they can call themselves.
+func resolve(sc *scope, name string, p pos) *definition {
+	for own := true; sc != nil; sc, own = sc.parent, false {
+		var first, last *definition
+		for _, def := range sc.defs {
+			if def.name.Value != name {
+				continue
+			}
+			if first == nil {
+				first = def
+			}
+			from := posOf(def.name.Token)
+			if own {
+				from = def.from
+			}
+			if def.prelude || !p.before(from) {
+				last = def
+			}
+		}
+		if last != nil {
+			return last
+		}
+		if first != nil {
+			return first
+		}
+	}
+	return nil
+}
+
+func (d *document) definitionOf(id *ident) *definition {
+	if id.def != nil {
+		return id.def
+	}
+	return resolve(id.scope, id.node.Value, posOf(id.node.Token))
+}
+
+var (
+	preludeOnce sync.Once
+	prelude     *scope
+)
+
+// preludeScope returns the scope of the functions in the prelude, the parent of the scopes of all documents.
+func preludeScope() *scope {
+	preludeOnce.Do(func() {
+		prelude = &scope{end: endPos}
+		for _, stmt := range evaluator.Prelude().Statements {
+			if let, ok := stmt.(*ast.LetStatement); ok {
+				prelude.defs = append(prelude.defs, &definition{name: let.Name, kind: defLet, value: let.Value, prelude: true})
+			}
+		}
+	})
+	return prelude
+}
+
+// signature describes def like "fn add(a, b = 1)" or "let x".
+func signature(def *definition) string {
+	name := def.name.Value
+	switch def.kind {
+	case defParam:
+		if def.value != nil {
+			return fmt.Sprintf("param %s = %s", name, format.Node(def.value))
+		}
+		return "param " + name
+	case defRest:
+		return "param ..." + name
+	case defFor:
+		return "for " + name
+	case defCatch:
+		return "catch (" + name + ")"
+	}
+	switch v := def.value.(type) {
+	case *ast.FunctionLiteral:
+		return fmt.Sprintf("fn %s(%s)", name, formatParameters(v.Parameters, v.Defaults, v.Rest))
+	case *ast.MacroLiteral:
+		return fmt.Sprintf("macro %s(%s)", name, formatParameters(v.Parameters, nil, nil))
+	}
+	return "let " + name
+}
+
+// formatParameters formats parameters like ast.FormatParameters but with the default values formatted as in the source.
+func formatParameters(params []*ast.Identifier, defaults []ast.Expression, rest *ast.Identifier) string {
+	var ss []string
+	for i, p := range params {
+		if isNil(p) {
+			continue
+		}
+		s := p.Value
+		if i < len(defaults) && !isNil(defaults[i]) {
+			s += " = " + format.Node(defaults[i])
+		}
+		ss = append(ss, s)
+	}
+	if !isNil(rest) {
+		ss = append(ss, "..."+rest.Value)
+	}
+	return strings.Join(ss, ", ")
+}
+
+func isFunction(def *definition) bool {
+	switch def.value.(type) {
+	case *ast.FunctionLiteral, *ast.MacroLiteral:
+		return def.kind == defLet
+	}
+	return false
+}
+
+// nodeEnd returns the position after node.
+func (d *document) nodeEnd(node ast.Node) pos {
+	closed := func(tok token.Token) pos {
+		if end, ok := d.closing[posOf(tok)]; ok {
+			return pos{end.row, end.col + 1}
+		}
+		return d.endOf(tok)
+	}
+	if isNil(node) {
+		return endPos
+	}
+	switch node := node.(type) {
+	case *ast.ExportStatement:
+		return d.nodeEnd(node.Statement)
+	case *ast.LetStatement:
+		if isNil(node.Value) {
+			return d.endOf(node.Name.Token)
+		}
+		return d.nodeEnd(node.Value)
+	case *ast.PrefixExpression:
+		return d.nodeEnd(node.Right)
+	case *ast.InfixExpression:
+		return d.nodeEnd(node.Right)
+	case *ast.AssignExpression:
+		return d.nodeEnd(node.Value)
+	case *ast.MemberExpression:
+		return d.nodeEnd(node.Member)
+	case *ast.IfExpression:
+		if !isNil(node.Alternative) {
+			return closed(node.Alternative.Token)
+		}
+		return closed(node.Consequence.Token)
+	case *ast.FunctionLiteral:
+		return closed(node.Body.Token)
+	case *ast.MacroLiteral:
+		return closed(node.Body.Token)
+	case *ast.ImportExpression:
+		end := d.nodeEnd(node.Path)
+		return pos{end.row, end.col + 1} // ")"
+	case *ast.CallExpression, *ast.ArrayLiteral, *ast.HashLiteral, *ast.IndexExpression:
+		return closed(tokenOf(node))
+	case *ast.Identifier:
+		return d.endOf(node.Token)
+	case *ast.StringLiteral:
+		return d.endOf(node.Token)
+	}
+	row, col := node.Pos()
+	return pos{row, col + utf8.RuneCountInString(node.TokenLiteral())}
+}
+
+func tokenOf(node ast.Node) token.Token {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return node.Token
+	case *ast.ArrayLiteral:
+		return node.Token
+	case *ast.HashLiteral:
+		return node.Token
+	case *ast.IndexExpression:
+		return node.Token
+	}
+	row, col := node.Pos()
+	return token.New(token.ILLEGAL, node.TokenLiteral(), row, col)
+}
+
+// symbols returns the let bindings in stmts and in the bodies of the functions bound by them.
+func (d *document) symbols(stmts []ast.Statement) []DocumentSymbol {
+	syms := []DocumentSymbol{}
+	for _, stmt := range stmts {
+		if isNil(stmt) {
+			continue
+		}
+		row, col := stmt.Pos()
+		start := pos{row, col}
+		var let *ast.LetStatement
+		switch stmt := stmt.(type) {
+		case *ast.LetStatement:
+			let = stmt
+		case *ast.ExportStatement:
+			let = stmt.Statement
+		}
+		if isNil(let) || isNil(let.Name) {
+			continue
+		}
+		def := &definition{name: let.Name, kind: defLet, value: let.Value}
+		sym := DocumentSymbol{
+			Name:           let.Name.Value,
+			Detail:         signature(def),
+			Kind:           SymbolVariable,
+			Range:          d.rangeOf(start, d.nodeEnd(stmt)),
+			SelectionRange: d.identRange(let.Name),
+		}
+		if fn, ok := let.Value.(*ast.FunctionLiteral); ok && !isNil(fn.Body) {
+			sym.Kind = SymbolFunction
+			if children := d.symbols(fn.Body.Statements); len(children) > 0 {
+				sym.Children = children
+			}
+		}
+		syms = append(syms, sym)
+	}
+	return syms
+}
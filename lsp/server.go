@@ -0,0 +1,310 @@
+// Package lsp implements a Language Server Protocol server for Monkey over a stream such as stdio.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/token"
+)
+
+// ErrNoShutdown is returned by Run if the client exits or closes the stream without a shutdown request.
+var ErrNoShutdown = errors.New("exit without shutdown")
+
+// Server is a language server that keeps open documents analyzed.
+type Server struct {
+	r        *bufio.Reader
+	w        io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// NewServer initializes a server that reads messages from r and writes to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		r:    bufio.NewReader(r),
+		w:    w,
+		docs: make(map[string]*document),
+	}
+}
+
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/documentSymbol": (*Server).documentSymbol,
+	"textDocument/completion":     (*Server).completion,
+}
+
+type notificationHandler func(s *Server, params json.RawMessage) error
+
+var notificationHandlers = map[string]notificationHandler{
+	"initialized":            func(*Server, json.RawMessage) error { return nil },
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Run serves requests until an exit notification or the end of the stream.
+func (s *Server) Run() error {
+	for {
+		msg, err := readMessage(s.r)
+		var rerr *responseError
+		switch {
+		case errors.As(err, &rerr):
+			if err := s.reply(nil, nil, rerr); err != nil {
+				return err
+			}
+			continue
+		case errors.Is(err, io.EOF):
+			return s.exit()
+		case err != nil:
+			return err
+		}
+		if msg.Method == "exit" {
+			return s.exit()
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) exit() error {
+	if !s.shutdown {
+		return ErrNoShutdown
+	}
+	return nil
+}
+
+// handle runs the handler of msg and replies to requests. It only fails if writing fails.
+func (s *Server) handle(msg *message) error {
+	if msg.ID == nil {
+		// notifications are ignored after shutdown and if unknown, including "$/" ones
+		if h, ok := notificationHandlers[msg.Method]; ok && !s.shutdown {
+			return h(s, msg.Params)
+		}
+		return nil
+	}
+	h, ok := handlers[msg.Method]
+	switch {
+	case !ok:
+		return s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method})
+	case s.shutdown:
+		return s.reply(msg.ID, nil, &responseError{Code: codeInvalidRequest, Message: "server is shut down"})
+	}
+	result, err := h(s, msg.Params)
+	if err != nil {
+		var rerr *responseError
+		if !errors.As(err, &rerr) {
+			rerr = &responseError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.reply(msg.ID, nil, rerr)
+	}
+	return s.reply(msg.ID, result, nil)
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rerr *responseError) error {
+	msg := &message{ID: id, Error: rerr}
+	if id == nil {
+		null := json.RawMessage("null")
+		msg.ID = &null // a response to a message that could not be read
+	}
+	if rerr == nil {
+		b, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = b
+	}
+	return writeMessage(s.w, msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	b, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return writeMessage(s.w, &message{Method: method, Params: b})
+}
+
+func unmarshalParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	d, ok := s.docs[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: "unknown document: " + uri}
+	}
+	return d, nil
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	type capabilities struct {
+		TextDocumentSync       int         `json:"textDocumentSync"`
+		DefinitionProvider     bool        `json:"definitionProvider"`
+		HoverProvider          bool        `json:"hoverProvider"`
+		DocumentSymbolProvider bool        `json:"documentSymbolProvider"`
+		CompletionProvider     interface{} `json:"completionProvider"`
+	}
+	return map[string]interface{}{
+		"capabilities": capabilities{
+			TextDocumentSync:       1, // full
+			DefinitionProvider:     true,
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     struct{}{},
+		},
+		"serverInfo": map[string]string{"name": "monkey"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil // a notification cannot be answered with an error
+	}
+	return s.update(p.TextDocument.URI, p.TextDocument.Text)
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil || len(p.ContentChanges) == 0 {
+		return nil
+	}
+	// the server asks for full sync so the last change has the whole text
+	return s.update(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+func (s *Server) update(uri, text string) error {
+	d := newDocument(uri, text)
+	s.docs[uri] = d
+	diags := d.diagnostics
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	return s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+// identAt finds the identifier at the position in params.
+func (s *Server) identAt(params json.RawMessage) (*document, *ident, error) {
+	var p TextDocumentPositionParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, d.identAt(d.pos(p.Position)), nil
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	d, id, err := s.identAt(params)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	def := d.definitionOf(id)
+	if def == nil || def.prelude {
+		return nil, nil
+	}
+	return Location{URI: d.uri, Range: d.identRange(def.name)}, nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	d, id, err := s.identAt(params)
+	if err != nil || id == nil {
+		return nil, err
+	}
+	var sig, note string
+	if def := d.definitionOf(id); def != nil {
+		sig = signature(def)
+		if def.prelude {
+			note = "Defined in the prelude."
+		}
+	} else if _, ok := evaluator.LookupBuiltin(id.node.Value); ok {
+		sig = "builtin " + id.node.Value
+	} else {
+		return nil, nil
+	}
+	value := "```monkey\n" + sig + "\n```"
+	if note != "" {
+		value += "\n" + note
+	}
+	r := d.identRange(id.node)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: value}, Range: &r}, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	return d.symbols(d.program.Statements), nil
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := unmarshalParams(params, &p); err != nil {
+		return nil, err
+	}
+	d, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	items := []CompletionItem{}
+	seen := make(map[string]bool)
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+	// inner scopes first so that shadowed names are described by the innermost definition
+	for sc := d.scopeAt(d.pos(p.Position)); sc != nil; sc = sc.parent {
+		for i := len(sc.defs) - 1; i >= 0; i-- {
+			def := sc.defs[i]
+			kind := CompletionVariable
+			if isFunction(def) {
+				kind = CompletionFunction
+			}
+			add(CompletionItem{Label: def.name.Value, Kind: kind, Detail: signature(def)})
+		}
+	}
+	for _, name := range evaluator.BuiltinNames() {
+		add(CompletionItem{Label: name, Kind: CompletionFunction, Detail: fmt.Sprintf("builtin %s", name)})
+	}
+	for _, kw := range token.Keywords() {
+		add(CompletionItem{Label: kw, Kind: CompletionKeyword})
+	}
+	return items, nil
+}
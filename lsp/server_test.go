@@ -0,0 +1,407 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ebiiim/monkey/lsp"
+)
+
+const uri = "file:///test.monkey"
+
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int            `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// client is a scripted LSP client talking to a Server through pipes.
+type client struct {
+	t      *testing.T
+	w      *io.PipeWriter
+	msgs   chan *rpcMessage
+	done   chan error
+	nextID int
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan *rpcMessage, 16), done: make(chan error, 1)}
+	go func() {
+		err := lsp.NewServer(inR, outW).Run()
+		outW.Close()
+		c.done <- err
+	}()
+	go func() {
+		r := bufio.NewReader(outR)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				close(c.msgs)
+				return
+			}
+			n, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				close(c.msgs)
+				return
+			}
+			var msg rpcMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Errorf("invalid message %s: %v", body, err)
+			}
+			c.msgs <- &msg
+		}
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return c
+}
+
+func (c *client) send(msg interface{}) {
+	c.t.Helper()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() *rpcMessage {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the stream")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timeout")
+	}
+	return nil
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *client) call(method string, params, result interface{}) *rpcMessage {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params})
+	for {
+		msg := c.receive()
+		if msg.ID == nil || *msg.ID != id {
+			continue
+		}
+		if msg.Error == nil && result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: invalid result %s: %v", method, msg.Result, err)
+			}
+		}
+		return msg
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// diagnostics waits for the diagnostics published for uri.
+func (c *client) diagnostics(uri string) []lsp.Diagnostic {
+	c.t.Helper()
+	for {
+		msg := c.receive()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p lsp.PublishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+// open starts a server and opens a document with text.
+func open(t *testing.T, text string) (*client, []lsp.Diagnostic) {
+	t.Helper()
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{"capabilities": struct{}{}}, nil)
+	c.notify("initialized", struct{}{})
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, Version: 1, Text: text},
+	})
+	return c, c.diagnostics(uri)
+}
+
+func at(line, char int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: char},
+	}
+}
+
+func rng(l1, c1, l2, c2 int) lsp.Range {
+	return lsp.Range{Start: lsp.Position{Line: l1, Character: c1}, End: lsp.Position{Line: l2, Character: c2}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.call("initialize", map[string]interface{}{"capabilities": struct{}{}}, &init)
+	for _, name := range []string{"textDocumentSync", "definitionProvider", "hoverProvider", "documentSymbolProvider", "completionProvider"} {
+		if _, ok := init.Capabilities[name]; !ok {
+			t.Errorf("capability %s not found in %v", name, init.Capabilities)
+		}
+	}
+	if msg := c.call("unknown/method", struct{}{}, nil); msg.Error == nil || msg.Error.Code != -32601 {
+		t.Errorf("want method not found got %+v", msg.Error)
+	}
+	if msg := c.call("textDocument/hover", at(0, 0), nil); msg.Error == nil || msg.Error.Code != -32602 {
+		t.Errorf("want invalid params for an unknown document got %+v", msg.Error)
+	}
+	if msg := c.call("shutdown", nil, nil); msg.Error != nil || string(msg.Result) != "null" {
+		t.Errorf("shutdown failed %+v %s", msg.Error, msg.Result)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := newClient(t)
+	c.notify("exit", nil)
+	if err := <-c.done; !errors.Is(err, lsp.ErrNoShutdown) {
+		t.Errorf("want %v got %v", lsp.ErrNoShutdown, err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []lsp.Diagnostic
+	}{
+		{"let x = 1;", []lsp.Diagnostic{}},
+		{"let = 1;", []lsp.Diagnostic{
+			{Range: rng(0, 4, 0, 5), Severity: lsp.SeverityError, Code: "ErrTokenType", Source: "monkey", Message: `expected "IDENT" but got "=" instead`},
+		}},
+		{"f(a b)\nlet x 1;", []lsp.Diagnostic{
+			{Range: rng(0, 4, 0, 5), Severity: lsp.SeverityError, Code: "ErrTokenType", Source: "monkey", Message: `expected "," but got "IDENT" instead`},
+			{Range: rng(1, 6, 1, 7), Severity: lsp.SeverityError, Code: "ErrTokenType", Source: "monkey", Message: `expected "=" but got "INT" instead`},
+		}},
+		{"let x 1;", []lsp.Diagnostic{
+			{Range: rng(0, 6, 0, 7), Severity: lsp.SeverityError, Code: "ErrTokenType", Source: "monkey", Message: `expected "=" but got "INT" instead`},
+		}},
+		{"let x = 1;\n\tlet y = \"a\\q\";", []lsp.Diagnostic{
+			{Range: rng(1, 11, 1, 13), Severity: lsp.SeverityError, Code: "ErrInvalidEscape", Source: "monkey", Message: `invalid escape sequence \q`},
+		}},
+		{"let s = \"😀\"; let x 10", []lsp.Diagnostic{
+			{Range: rng(0, 20, 0, 22), Severity: lsp.SeverityError, Code: "ErrTokenType", Source: "monkey", Message: `expected "=" but got "INT" instead`},
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.input, func(t *testing.T) {
+			_, got := open(t, c.input)
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("want=%+v got=%+v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestDidChangeAndClose(t *testing.T) {
+	c, diags := open(t, "let x 1")
+	if len(diags) != 1 {
+		t.Fatalf("want 1 diagnostic got %+v", diags)
+	}
+	c.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]string{{"text": "let x = 1"}},
+	})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("want no diagnostics got %+v", diags)
+	}
+	var loc *lsp.Location
+	c.call("textDocument/definition", at(0, 4), &loc)
+	if loc == nil || loc.Range != rng(0, 4, 0, 5) {
+		t.Errorf("changed text is not analyzed: %+v", loc)
+	}
+	c.notify("textDocument/didClose", lsp.DidCloseTextDocumentParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}})
+	if diags := c.diagnostics(uri); len(diags) != 0 {
+		t.Errorf("want diagnostics cleared got %+v", diags)
+	}
+}
+
+const program = `let add = fn(a, b = 1) { a + b };
+let x = add(2);
+let f = fn(x, ...rest) {
+	let y = x * 2;
+	for (i in rest) { y += i }
+	try { g(y) } catch (e) { puts(e) }
+	y
+};
+let g = fn() { f(x) };
+let x = map([x], add);
+len(héllo);
+`
+
+func TestDefinition(t *testing.T) {
+	cases := []struct {
+		line, char int
+		want       *lsp.Range // nil if there is no definition in the document
+	}{
+		{1, 9, &lsp.Range{Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 7}}},    // add
+		{0, 26, &lsp.Range{Start: lsp.Position{Line: 0, Character: 13}, End: lsp.Position{Line: 0, Character: 14}}}, // a
+		{0, 30, &lsp.Range{Start: lsp.Position{Line: 0, Character: 16}, End: lsp.Position{Line: 0, Character: 17}}}, // b
+		{3, 9, &lsp.Range{Start: lsp.Position{Line: 2, Character: 11}, End: lsp.Position{Line: 2, Character: 12}}},  // parameter x shadows let x
+		{4, 11, &lsp.Range{Start: lsp.Position{Line: 2, Character: 17}, End: lsp.Position{Line: 2, Character: 21}}}, // rest
+		{4, 19, &lsp.Range{Start: lsp.Position{Line: 3, Character: 5}, End: lsp.Position{Line: 3, Character: 6}}},   // y
+		{4, 25, &lsp.Range{Start: lsp.Position{Line: 4, Character: 6}, End: lsp.Position{Line: 4, Character: 7}}},   // for variable i
+		{5, 7, &lsp.Range{Start: lsp.Position{Line: 8, Character: 4}, End: lsp.Position{Line: 8, Character: 5}}},    // g defined later
+		{5, 31, &lsp.Range{Start: lsp.Position{Line: 5, Character: 21}, End: lsp.Position{Line: 5, Character: 22}}}, // catch parameter
+		{8, 17, &lsp.Range{Start: lsp.Position{Line: 1, Character: 4}, End: lsp.Position{Line: 1, Character: 5}}},   // x before it is rebound
+		{9, 13, &lsp.Range{Start: lsp.Position{Line: 1, Character: 4}, End: lsp.Position{Line: 1, Character: 5}}},   // x in its rebinding
+		{9, 4, &lsp.Range{Start: lsp.Position{Line: 9, Character: 4}, End: lsp.Position{Line: 9, Character: 5}}},    // a definition itself
+		{9, 8, nil},  // map is in the prelude
+		{10, 0, nil}, // len is a builtin
+		{10, 4, nil}, // héllo is not defined
+		{0, 10, nil}, // not an identifier
+	}
+	c, diags := open(t, program)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics %+v", diags)
+	}
+	for _, tc := range cases {
+		var got *lsp.Location
+		if msg := c.call("textDocument/definition", at(tc.line, tc.char), &got); msg.Error != nil {
+			t.Fatalf("%d:%d unexpected error %+v", tc.line, tc.char, msg.Error)
+		}
+		switch {
+		case tc.want == nil && got != nil:
+			t.Errorf("%d:%d want no definition got %+v", tc.line, tc.char, got)
+		case tc.want != nil && (got == nil || got.URI != uri || got.Range != *tc.want):
+			t.Errorf("%d:%d want %+v got %+v", tc.line, tc.char, *tc.want, got)
+		}
+	}
+}
+
+func TestHover(t *testing.T) {
+	cases := []struct {
+		line, char int
+		want       string // "" if there is no hover
+	}{
+		{1, 8, "```monkey\nfn add(a, b = 1)\n```"},
+		{2, 5, "```monkey\nfn f(x, ...rest)\n```"},
+		{0, 16, "```monkey\nparam b = 1\n```"},
+		{3, 5, "```monkey\nlet y\n```"},
+		{9, 9, "```monkey\nfn map(arr, f)\n```\nDefined in the prelude."},
+		{10, 1, "```monkey\nbuiltin len\n```"},
+		{10, 6, ""},
+	}
+	c, _ := open(t, program)
+	for _, tc := range cases {
+		var got *lsp.Hover
+		c.call("textDocument/hover", at(tc.line, tc.char), &got)
+		switch {
+		case tc.want == "" && got != nil:
+			t.Errorf("%d:%d want no hover got %+v", tc.line, tc.char, got)
+		case tc.want != "" && (got == nil || got.Contents.Value != tc.want || got.Contents.Kind != "markdown"):
+			t.Errorf("%d:%d want %q got %+v", tc.line, tc.char, tc.want, got)
+		}
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	src := "let add = fn(a, b) {\n\tlet sum = a + b;\n\tsum\n};\nexport let pi = 3;\nlet = 1;\n"
+	want := []lsp.DocumentSymbol{
+		{Name: "add", Detail: "fn add(a, b)", Kind: lsp.SymbolFunction, Range: rng(0, 0, 3, 1), SelectionRange: rng(0, 4, 0, 7), Children: []lsp.DocumentSymbol{
+			{Name: "sum", Detail: "let sum", Kind: lsp.SymbolVariable, Range: rng(1, 1, 1, 16), SelectionRange: rng(1, 5, 1, 8)},
+		}},
+		{Name: "pi", Detail: "let pi", Kind: lsp.SymbolVariable, Range: rng(4, 0, 4, 17), SelectionRange: rng(4, 11, 4, 13)},
+	}
+	c, _ := open(t, src)
+	var got []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}, &got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("want=%+v\ngot=%+v", want, got)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c, _ := open(t, program)
+	var got []lsp.CompletionItem
+	c.call("textDocument/completion", at(6, 1), &got) // in the body of f
+	items := make(map[string]lsp.CompletionItem)
+	for _, item := range got {
+		if _, ok := items[item.Label]; ok {
+			t.Errorf("duplicate item %s", item.Label)
+		}
+		items[item.Label] = item
+	}
+	want := []lsp.CompletionItem{
+		{Label: "y", Kind: lsp.CompletionVariable, Detail: "let y"},
+		{Label: "x", Kind: lsp.CompletionVariable, Detail: "param x"}, // the parameter shadows the global
+		{Label: "rest", Kind: lsp.CompletionVariable, Detail: "param ...rest"},
+		{Label: "add", Kind: lsp.CompletionFunction, Detail: "fn add(a, b = 1)"},
+		{Label: "g", Kind: lsp.CompletionFunction, Detail: "fn g()"},
+		{Label: "map", Kind: lsp.CompletionFunction, Detail: "fn map(arr, f)"},
+		{Label: "len", Kind: lsp.CompletionFunction, Detail: "builtin len"},
+		{Label: "while", Kind: lsp.CompletionKeyword},
+	}
+	for _, w := range want {
+		if items[w.Label] != w {
+			t.Errorf("want %+v got %+v", w, items[w.Label])
+		}
+	}
+	for _, name := range []string{"a", "b", "i", "e"} {
+		if _, ok := items[name]; ok {
+			t.Errorf("%s is not in scope", name)
+		}
+	}
+	if !strings.HasPrefix(got[0].Label, "y") {
+		t.Errorf("want inner names first got %s", got[0].Label)
+	}
+}
+
+// TestIncompleteSource checks that partial programs, as seen while typing, are analyzed without crashing the server.
+func TestIncompleteSource(t *testing.T) {
+	c, _ := open(t, "")
+	for i := range program {
+		c.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": i + 2},
+			"contentChanges": []map[string]string{{"text": program[:i]}},
+		})
+		c.diagnostics(uri)
+		for _, method := range []string{"textDocument/definition", "textDocument/hover", "textDocument/completion"} {
+			if msg := c.call(method, at(strings.Count(program[:i], "\n"), 1), nil); msg.Error != nil {
+				t.Fatalf("%s at %d: %+v", method, i, msg.Error)
+			}
+		}
+		if msg := c.call("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]string{"uri": uri}}, nil); msg.Error != nil {
+			t.Fatalf("documentSymbol at %d: %+v", i, msg.Error)
+		}
+	}
+}
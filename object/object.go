@@ -3,8 +3,13 @@ package object
 import (
 	"bytes"
 	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
 
 	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/code"
 )
 
 // Type represents type (in Monkey language) of the Object.
@@ -14,6 +19,7 @@ type Type string
 const (
 	NULL_OBJ         = "NULL"
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
 	ERROR_OBJ        = "ERROR"
@@ -21,6 +27,15 @@ const (
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	RANGE_OBJ        = "RANGE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	MODULE_OBJ       = "MODULE"
+	QUOTE_OBJ        = "QUOTE"
+	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type Object interface {
@@ -39,18 +54,42 @@ func (o *Null) Inspect() string { return "null" }
 // Integer contains an INTEGER type value.
 type Integer struct{ Value int64 }
 
-var _ Object = (*Integer)(nil)
+var _ Hashable = (*Integer)(nil)
 
-func (o *Integer) Type() Type      { return INTEGER_OBJ }
-func (o *Integer) Inspect() string { return fmt.Sprint(o.Value) }
+func (o *Integer) Type() Type       { return INTEGER_OBJ }
+func (o *Integer) Inspect() string  { return fmt.Sprint(o.Value) }
+func (o *Integer) HashKey() HashKey { return HashKey{Type: o.Type(), Value: uint64(o.Value)} }
+
+// Float contains a FLOAT type value.
+type Float struct{ Value float64 }
+
+var _ Object = (*Float)(nil)
+
+func (o *Float) Type() Type { return FLOAT_OBJ }
+
+// Inspect formats the value so that it is distinguishable from an Integer, e.g. "2.0".
+func (o *Float) Inspect() string {
+	s := strconv.FormatFloat(o.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") { // has a fraction, an exponent, Inf or NaN
+		return s
+	}
+	return s + ".0"
+}
 
 // Boolean contains a BOOLEAN type value.
 type Boolean struct{ Value bool }
 
-var _ Object = (*Boolean)(nil)
+var _ Hashable = (*Boolean)(nil)
 
 func (o *Boolean) Type() Type      { return BOOLEAN_OBJ }
 func (o *Boolean) Inspect() string { return fmt.Sprint(o.Value) }
+func (o *Boolean) HashKey() HashKey {
+	var v uint64
+	if o.Value {
+		v = 1
+	}
+	return HashKey{Type: o.Type(), Value: v}
+}
 
 // ReturnValue wraps an Object as the return value used by evaluator.
 type ReturnValue struct{ Value Object }
@@ -60,17 +99,88 @@ var _ Object = (*ReturnValue)(nil)
 func (o *ReturnValue) Type() Type      { return RETURN_VALUE_OBJ }
 func (o *ReturnValue) Inspect() string { return o.Value.Inspect() }
 
+// Break signals a break statement to the enclosing loop.
+type Break struct{}
+
+var _ Object = (*Break)(nil)
+
+func (o *Break) Type() Type      { return BREAK_OBJ }
+func (o *Break) Inspect() string { return "break" }
+
+// Continue signals a continue statement to the enclosing loop.
+type Continue struct{}
+
+var _ Object = (*Continue)(nil)
+
+func (o *Continue) Type() Type      { return CONTINUE_OBJ }
+func (o *Continue) Inspect() string { return "continue" }
+
 // Error contains an error that is used by evaluator.
-type Error struct{ Message error }
+// It also implements error so Go callers can use errors.Is and errors.As.
+type Error struct {
+	Message  error
+	File     string  // source of Row and Col: empty for the main program, or a module file or "<prelude>"
+	Row, Col int     // position of the node that failed; zero if unknown
+	Stack    []Frame // active function calls, innermost first
+}
+
+// Frame is a function call in the stack of an Error.
+type Frame struct {
+	Name     string // empty if the function is anonymous
+	File     string // source of the call site as in Error
+	Row, Col int    // position of the call site
+}
+
+func (f Frame) String() string {
+	name := f.Name
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("%s (called at %d:%d)", name, f.Row, f.Col)
+}
 
 var _ Object = (*Error)(nil)
+var _ error = (*Error)(nil)
 
 func (o *Error) Type() Type      { return ERROR_OBJ }
-func (o *Error) Inspect() string { return fmt.Sprintf("ERROR: %s", o.Message) }
+func (o *Error) Inspect() string { return fmt.Sprintf("ERROR: %s", o.Error()) }
+func (o *Error) Unwrap() error   { return o.Message }
+func (o *Error) Error() string {
+	if o.Row == 0 {
+		return o.Message.Error()
+	}
+	return fmt.Sprintf("%d:%d %s", o.Row, o.Col, o.Message)
+}
+
+// StackTrace returns the stack in lines like "\tat add (called at 4:1)\n".
+// Runs of more than two identical frames, as in recursion, are collapsed into "\t... repeated N more times\n".
+func (o *Error) StackTrace() string {
+	var out bytes.Buffer
+	for i := 0; i < len(o.Stack); {
+		f := o.Stack[i]
+		n := 1
+		for i+n < len(o.Stack) && o.Stack[i+n] == f {
+			n++
+		}
+		fmt.Fprintf(&out, "\tat %s\n", f)
+		switch {
+		case n > 2:
+			fmt.Fprintf(&out, "\t... repeated %d more times\n", n-1)
+		case n == 2:
+			fmt.Fprintf(&out, "\tat %s\n", f)
+		}
+		i += n
+	}
+	return out.String()
+}
 
 type Function struct {
 	Env        *Environment
+	Name       string
+	File       string // source of Body as in Error
 	Parameters []*ast.Identifier
+	Defaults   []ast.Expression // Defaults[i] is the default value of Parameters[i] or nil
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 }
 
@@ -79,25 +189,75 @@ var _ Object = (*Function)(nil)
 func (o *Function) Type() Type { return FUNCTION_OBJ }
 func (o *Function) Inspect() string {
 	var out bytes.Buffer
-	fmt.Fprint(&out, "fn(")
-	for i, param := range o.Parameters {
-		fmt.Fprint(&out, param)
-		if i+1 != len(o.Parameters) {
-			fmt.Fprint(&out, ", ")
-		}
-	}
-	fmt.Fprint(&out, ") {\n")
+	fmt.Fprintf(&out, "fn(%s) {\n", ast.FormatParameters(o.Parameters, o.Defaults, o.Rest))
 	fmt.Fprint(&out, o.Body.String())
 	fmt.Fprint(&out, "\n}")
 	return out.String()
 }
 
+// Quote holds an unevaluated node returned by quote.
+type Quote struct {
+	Node ast.Node
+}
+
+var _ Object = (*Quote)(nil)
+
+func (o *Quote) Type() Type      { return QUOTE_OBJ }
+func (o *Quote) Inspect() string { return fmt.Sprintf("QUOTE(%s)", o.Node) }
+
+// Macro is defined by a MacroLiteral and expanded before evaluation.
+type Macro struct {
+	Env        *Environment
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+}
+
+var _ Object = (*Macro)(nil)
+
+func (o *Macro) Type() Type { return MACRO_OBJ }
+func (o *Macro) Inspect() string {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "macro(%s) {\n", ast.FormatParameters(o.Parameters, nil, nil))
+	fmt.Fprint(&out, o.Body.String())
+	fmt.Fprint(&out, "\n}")
+	return out.String()
+}
+
+// CompiledFunction contains bytecode of a function used by vm.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+}
+
+var _ Object = (*CompiledFunction)(nil)
+
+func (o *CompiledFunction) Type() Type      { return COMPILED_FUNCTION_OBJ }
+func (o *CompiledFunction) Inspect() string { return fmt.Sprintf("CompiledFunction[%p]", o) }
+
+// Closure wraps a CompiledFunction with its free variables.
+// It is a FUNCTION in Monkey language as Function is.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+var _ Object = (*Closure)(nil)
+
+func (o *Closure) Type() Type      { return FUNCTION_OBJ }
+func (o *Closure) Inspect() string { return fmt.Sprintf("Closure[%p]", o) }
+
 type String struct{ Value string }
 
-var _ Object = (*String)(nil)
+var _ Hashable = (*String)(nil)
 
 func (o *String) Type() Type      { return STRING_OBJ }
 func (o *String) Inspect() string { return o.Value }
+func (o *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(o.Value))
+	return HashKey{Type: o.Type(), Value: h.Sum64()}
+}
 
 type BuiltinFunction func(arg ...Object) Object
 type Builtin struct{ Fn BuiltinFunction }
@@ -122,3 +282,152 @@ func (o *Array) Inspect() string {
 	fmt.Fprint(&out, "]")
 	return out.String()
 }
+
+// Range is an arithmetic sequence of integers from Start up to but not including Stop.
+// Step must not be 0.
+type Range struct {
+	Start, Stop, Step int64
+}
+
+var _ Object = (*Range)(nil)
+
+func (o *Range) Type() Type { return RANGE_OBJ }
+func (o *Range) Inspect() string {
+	if o.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", o.Start, o.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", o.Start, o.Stop, o.Step)
+}
+
+// Len returns the number of elements, at most math.MaxInt64.
+func (o *Range) Len() int64 {
+	var dist, step uint64 // computed in uint64 to avoid overflow
+	switch {
+	case o.Step > 0 && o.Start < o.Stop:
+		dist, step = uint64(o.Stop)-uint64(o.Start), uint64(o.Step)
+	case o.Step < 0 && o.Start > o.Stop:
+		dist, step = uint64(o.Start)-uint64(o.Stop), -uint64(o.Step)
+	default:
+		return 0
+	}
+	n := (dist-1)/step + 1
+	if n > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(n)
+}
+
+// At returns the i-th element. i must be in [0, Len()).
+func (o *Range) At(i int64) int64 {
+	return o.Start + i*o.Step
+}
+
+// HashKey is used as a key of Hash.
+type HashKey struct {
+	Type  Type
+	Value uint64
+}
+
+// Hashable is implemented by Objects that can be used as a key of Hash.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+// HashPair contains a key and a value stored in Hash.
+type HashPair struct {
+	Key   Hashable
+	Value Object
+}
+
+// Module is an imported module. Exports holds the bindings it exports.
+type Module struct {
+	Name    string // the path given to import
+	Exports map[string]Object
+}
+
+var _ Object = (*Module)(nil)
+
+func (o *Module) Type() Type      { return MODULE_OBJ }
+func (o *Module) Inspect() string { return fmt.Sprintf("module(%q)", o.Name) }
+
+// Hash contains a HASH type value. Pairs are kept in insertion order.
+type Hash struct {
+	index map[HashKey]int
+	pairs []HashPair
+}
+
+var _ Object = (*Hash)(nil)
+
+// NewHash initializes an empty Hash.
+func NewHash() *Hash {
+	return &Hash{index: make(map[HashKey]int)}
+}
+
+func (o *Hash) Type() Type { return HASH_OBJ }
+func (o *Hash) Inspect() string {
+	var out bytes.Buffer
+	fmt.Fprint(&out, "{")
+	for i, pair := range o.pairs {
+		fmt.Fprintf(&out, "%s: %s", pair.Key.Inspect(), pair.Value.Inspect())
+		if i+1 != len(o.pairs) {
+			fmt.Fprint(&out, ", ")
+		}
+	}
+	fmt.Fprint(&out, "}")
+	return out.String()
+}
+
+// Get returns the value associated with the key.
+func (o *Hash) Get(key Hashable) (Object, bool) {
+	i, ok := o.index[key.HashKey()]
+	if !ok {
+		return nil, false
+	}
+	return o.pairs[i].Value, true
+}
+
+// Set associates the value with the key.
+func (o *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if i, ok := o.index[hk]; ok {
+		o.pairs[i] = HashPair{Key: key, Value: value}
+		return
+	}
+	o.index[hk] = len(o.pairs)
+	o.pairs = append(o.pairs, HashPair{Key: key, Value: value})
+}
+
+// Delete removes the key and reports whether it was found.
+func (o *Hash) Delete(key Hashable) bool {
+	i, ok := o.index[key.HashKey()]
+	if !ok {
+		return false
+	}
+	delete(o.index, key.HashKey())
+	o.pairs = append(o.pairs[:i], o.pairs[i+1:]...)
+	for j := i; j < len(o.pairs); j++ {
+		o.index[o.pairs[j].Key.HashKey()] = j
+	}
+	return true
+}
+
+// Len returns the number of pairs.
+func (o *Hash) Len() int { return len(o.pairs) }
+
+// Pairs returns the pairs in insertion order.
+// The returned slice must not be modified.
+func (o *Hash) Pairs() []HashPair { return o.pairs }
+
+// Copy returns a shallow copy of the Hash.
+func (o *Hash) Copy() *Hash {
+	h := &Hash{
+		index: make(map[HashKey]int, len(o.index)),
+		pairs: make([]HashPair, len(o.pairs)),
+	}
+	copy(h.pairs, o.pairs)
+	for k, v := range o.index {
+		h.index[k] = v
+	}
+	return h
+}
@@ -0,0 +1,155 @@
+package object_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ebiiim/monkey/object"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &object.String{Value: "Hello World"}
+	hello2 := &object.String{Value: "Hello World"}
+	diff1 := &object.String{Value: "My name is johnny"}
+	diff2 := &object.String{Value: "My name is johnny"}
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeyType(t *testing.T) {
+	one := &object.Integer{Value: 1}
+	tru := &object.Boolean{Value: true}
+	if one.HashKey() == tru.HashKey() {
+		t.Errorf("1 and true have same hash keys")
+	}
+}
+
+func TestHash(t *testing.T) {
+	h := object.NewHash()
+	a, b, c := &object.String{Value: "a"}, &object.String{Value: "b"}, &object.String{Value: "c"}
+	h.Set(a, &object.Integer{Value: 1})
+	h.Set(b, &object.Integer{Value: 2})
+	h.Set(c, &object.Integer{Value: 3})
+	h.Set(a, &object.Integer{Value: 4})
+	if want := "{a: 4, b: 2, c: 3}"; h.Inspect() != want {
+		t.Fatalf("h.Inspect() want=%s got=%s", want, h.Inspect())
+	}
+	cp := h.Copy()
+	if !h.Delete(b) {
+		t.Fatal("h.Delete(b) returned false")
+	}
+	if h.Delete(b) {
+		t.Fatal("h.Delete(b) returned true twice")
+	}
+	if want := "{a: 4, c: 3}"; h.Inspect() != want {
+		t.Fatalf("h.Inspect() want=%s got=%s", want, h.Inspect())
+	}
+	if v, ok := h.Get(c); !ok || v.Inspect() != "3" {
+		t.Fatalf("h.Get(c) want=3 got=%v", v)
+	}
+	if want := "{a: 4, b: 2, c: 3}"; cp.Inspect() != want {
+		t.Fatalf("cp.Inspect() want=%s got=%s", want, cp.Inspect())
+	}
+}
+
+func TestEnvironmentAssign(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Set("a", &object.Integer{Value: 1})
+	inner := object.NewEnclosedEnvironment(outer)
+	inner.Set("b", &object.Integer{Value: 2})
+
+	if !inner.Assign("a", &object.Integer{Value: 10}) {
+		t.Fatal("Assign(a) failed")
+	}
+	if !inner.Assign("b", &object.Integer{Value: 20}) {
+		t.Fatal("Assign(b) failed")
+	}
+	if inner.Assign("c", &object.Integer{Value: 30}) {
+		t.Error("Assign(c) want=false")
+	}
+	if v, _ := outer.Get("a"); v.Inspect() != "10" {
+		t.Errorf("outer a want=10 got=%s", v.Inspect())
+	}
+	if _, ok := outer.Get("b"); ok {
+		t.Error("b leaked to outer")
+	}
+	if _, ok := outer.Get("c"); ok {
+		t.Error("c was defined")
+	}
+}
+
+func TestEnvironmentChain(t *testing.T) {
+	outer := object.NewEnvironment()
+	outer.Set("z", &object.Integer{Value: 1})
+	inner := object.NewEnclosedEnvironment(outer)
+	inner.Set("b", &object.Integer{Value: 2})
+	inner.Set("a", &object.Integer{Value: 3})
+
+	if got := strings.Join(inner.Names(), ","); got != "a,b" {
+		t.Errorf("inner.Names() want=a,b got=%s", got)
+	}
+	if inner.Outer() != outer {
+		t.Error("inner.Outer() is not outer")
+	}
+	if outer.Outer() != nil {
+		t.Error("outer.Outer() want=nil")
+	}
+}
+
+func TestRange(t *testing.T) {
+	cases := []struct {
+		r       object.Range
+		wantLen int64
+		wantStr string
+	}{
+		{object.Range{Start: 0, Stop: 3, Step: 1}, 3, "range(0, 3)"},
+		{object.Range{Start: 3, Stop: 0, Step: 1}, 0, "range(3, 0)"},
+		{object.Range{Start: 0, Stop: 10, Step: 3}, 4, "range(0, 10, 3)"},
+		{object.Range{Start: 10, Stop: 0, Step: -5}, 2, "range(10, 0, -5)"},
+		{object.Range{Start: 0, Stop: 1, Step: -1}, 0, "range(0, 1, -1)"},
+		{object.Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1}, math.MaxInt64, "range(-9223372036854775808, 9223372036854775807)"},
+		{object.Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64}, 3, "range(-9223372036854775808, 9223372036854775807, 9223372036854775807)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.wantStr, func(t *testing.T) {
+			if got := c.r.Len(); got != c.wantLen {
+				t.Errorf("Len() want=%d got=%d", c.wantLen, got)
+			}
+			if got := c.r.Inspect(); got != c.wantStr {
+				t.Errorf("Inspect() want=%s got=%s", c.wantStr, got)
+			}
+			if c.wantLen > 0 && c.r.At(0) != c.r.Start {
+				t.Errorf("At(0) want=%d got=%d", c.r.Start, c.r.At(0))
+			}
+		})
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	cases := []struct {
+		v    float64
+		want string
+	}{
+		{0, "0.0"},
+		{2, "2.0"},
+		{-0.15, "-0.15"},
+		{1e21, "1e+21"},
+		{1e-7, "1e-07"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, c := range cases {
+		if got := (&object.Float{Value: c.v}).Inspect(); got != c.want {
+			t.Errorf("Inspect(%v) want=%s got=%s", c.v, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/token"
+)
+
+// Severity is the severity of a Diagnostic.
+type Severity int
+
+// SeverityError is the severity of all diagnostics of the parser, as it has no warnings yet.
+const SeverityError Severity = 1
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Position is a position in the source as in tokens.
+type Position struct {
+	Row, Col int
+}
+
+// Diagnostic is an error found in the source from Start to End (exclusive).
+// Code is the name of the error such as "ErrTokenType" and does not change between versions.
+type Diagnostic struct {
+	Start, End Position
+	Severity   Severity
+	Code       string
+	Message    string // without the position and the code
+	Err        error  // the same error as in Errors()
+}
+
+// tokenError reports an error of kind at tok.
+func (p *Parser) tokenError(tok token.Token, kind error, format string, a ...interface{}) {
+	p.addError(Position{tok.Row, tok.Col}, p.tokenEnd(tok), kind, fmt.Sprintf(format, a...))
+}
+
+func (p *Parser) addError(start, end Position, kind error, msg string) {
+	err := fmt.Errorf("%d:%d %s (%w)", start.Row, start.Col, msg, kind)
+	p.addDiagnostic(Diagnostic{Start: start, End: end, Severity: SeverityError, Code: kind.Error(), Message: msg}, err)
+}
+
+// addDiagnostic adds d for err to the errors.
+func (p *Parser) addDiagnostic(d Diagnostic, err error) {
+	de := &diagnosticError{error: err}
+	d.Err = de
+	de.d = d
+	p.errs = append(p.errs, de)
+	p.diags = append(p.diags, d)
+}
+
+// diagnosticError is an error in Errors() that keeps its Diagnostic.
+type diagnosticError struct {
+	error
+	d Diagnostic
+}
+
+func (e *diagnosticError) Unwrap() error { return e.error }
+
+// DiagnosticOf returns the Diagnostic of err if it wraps an error returned by Errors().
+func DiagnosticOf(err error) (Diagnostic, bool) {
+	var de *diagnosticError
+	if !errors.As(err, &de) {
+		return Diagnostic{}, false
+	}
+	return de.d, true
+}
+
+// lexerError reports err of the lexer found at peekToken.
+func (p *Parser) lexerError(err error) {
+	d := Diagnostic{Severity: SeverityError, Message: err.Error()}
+	var lexErr *lexer.Error
+	if errors.As(err, &lexErr) {
+		d.Start = Position{lexErr.Row, lexErr.Col}
+		d.End = Position{lexErr.EndRow, lexErr.EndCol}
+		d.Code = lexErr.Kind.Error()
+		d.Message = lexErr.Message
+	}
+	p.addDiagnostic(d, err)
+}
+
+// tokenEnd returns the position after tok in the source.
+func (p *Parser) tokenEnd(tok token.Token) Position {
+	switch {
+	case tok.Type == token.EOF:
+		return Position{tok.Row, tok.Col}
+	case tok == p.peekToken:
+		return p.peekEnd
+	case tok == p.curToken:
+		return p.curEnd
+	}
+	// other tokens are keywords and identifiers whose literals are as in the source
+	return Position{tok.Row, tok.Col + utf8.RuneCountInString(tok.Literal)}
+}
@@ -2,8 +2,8 @@ package parser
 
 import (
 	"errors"
-	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/ebiiim/monkey/ast"
 	"github.com/ebiiim/monkey/lexer"
@@ -15,6 +15,10 @@ var (
 	ErrTokenType      = errors.New("ErrTokenType")
 	ErrInvalidLiteral = errors.New("ErrInvalidLiteral")
 	ErrNoParseFunc    = errors.New("ErrNoParseFunc")
+	ErrInvalidParam   = errors.New("ErrInvalidParam")
+	ErrInvalidAssign  = errors.New("ErrInvalidAssign")
+	ErrOutsideLoop    = errors.New("ErrOutsideLoop")
+	ErrNotTopLevel    = errors.New("ErrNotTopLevel")
 )
 
 type (
@@ -25,33 +29,57 @@ type (
 // Precedences
 const (
 	LOWEST     = iota + 1
+	ASSIGN     // = or +=
+	OR         // ||
+	AND        // &&
 	EQUALS     // ==
 	LESSGRATER // < or >
 	SUM        // +
-	PRODUCT    // *
+	PRODUCT    // * or %
 	PREFIX     // -X or !X
 	CALL       // fn(X)
 	INDEX      // array[index]
 )
 
 var precedences = map[token.Type]int{
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQ:       EQUALS,
 	token.NEQ:      EQUALS,
 	token.LT:       LESSGRATER,
 	token.GT:       LESSGRATER,
+	token.LTE:      LESSGRATER,
+	token.GTE:      LESSGRATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LPAREN:   CALL,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
 type Parser struct {
 	l              *lexer.Lexer
 	errs           []error
+	diags          []Diagnostic // for errs in the same order
+	numLexerErrs   int
+	curLexErr      bool // the lexer reported an error at curToken
+	peekLexErr     bool
+	comments       []token.Token
+	loopDepth      int // loops enclosing the current token within the current function
+	depth          int // brackets opened and not closed up to and including the current token
 	curToken       token.Token
 	peekToken      token.Token
+	curEnd         Position // the end of curToken in the source
+	peekEnd        Position
 	prefixParseFns map[token.Type]prefixParseFn
 	infixParseFns  map[token.Type]infixParseFn
 }
@@ -64,26 +92,40 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(token.FALSE, p.parseBooleanLiteral)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
-	p.registerPrefix(token.RPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	p.infixParseFns = make(map[token.Type]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NEQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LTE, p.parseInfixExpression)
+	p.registerInfix(token.GTE, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 
 	// set the first token
 	p.nextToken()
@@ -95,17 +137,77 @@ func (p *Parser) Errors() []error {
 	return p.errs
 }
 
+// Diagnostics returns the errors found so far with their ranges and codes.
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diags
+}
+
+// Err returns the errors as an ErrorList, or nil if there are none.
+func (p *Parser) Err() error {
+	if len(p.errs) == 0 {
+		return nil
+	}
+	return ErrorList(p.errs)
+}
+
+// ErrorList is a list of parser errors used as a single error.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	ss := make([]string, len(l))
+	for i, err := range l {
+		ss[i] = err.Error()
+	}
+	return strings.Join(ss, "\n")
+}
+
+// Is reports whether any error in the list matches target.
+func (l ErrorList) Is(target error) bool {
+	for _, err := range l {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Comments returns comments skipped so far. The lexer must be initialized with lexer.NewWithComments.
+func (p *Parser) Comments() []token.Token {
+	return p.comments
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curEnd = p.peekEnd
+	p.curLexErr = p.peekLexErr
+	switch p.curToken.Type {
+	case token.LPAREN, token.LBRACE, token.LBRACKET:
+		p.depth++
+	case token.RPAREN, token.RBRACE, token.RBRACKET:
+		p.depth--
+	}
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.comments = append(p.comments, p.peekToken)
+		p.peekToken = p.l.NextToken()
+	}
+	p.peekEnd.Row, p.peekEnd.Col = p.l.Pos()
+	// lexical errors come before syntax errors found at the same token
+	p.peekLexErr = false
+	if lexErrs := p.l.Errors(); len(lexErrs) > p.numLexerErrs {
+		p.peekLexErr = true
+		for _, err := range lexErrs[p.numLexerErrs:] {
+			p.lexerError(err)
+		}
+		p.numLexerErrs = len(lexErrs)
+	}
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt := p.parseStatementOrSync(0); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
@@ -113,12 +215,67 @@ func (p *Parser) ParseProgram() *ast.Program {
 	return program
 }
 
+// parseStatementOrSync parses a statement in a block at depth and
+// skips the rest of the statement if it has errors so that they are not followed by errors in its remains.
+func (p *Parser) parseStatementOrSync(depth int) ast.Statement {
+	numErrs := len(p.errs)
+	stmt := p.parseStatement()
+	if len(p.errs) > numErrs && !p.atStatementEnd(depth) {
+		p.synchronize(depth)
+	}
+	return stmt
+}
+
+// atStatementEnd reports whether the current token can end a statement in a block at depth.
+func (p *Parser) atStatementEnd(depth int) bool {
+	if p.depth != depth {
+		return false
+	}
+	switch p.peekToken.Type {
+	case token.SEMICOLON, token.RBRACE, token.EOF, token.LET, token.RETURN:
+		return true
+	}
+	return p.curTokenIs(token.SEMICOLON) || p.peekToken.Row > p.curToken.Row
+}
+
+// synchronize skips tokens up to a ";" or before a "}" closing the block at depth, a let or return statement, or the end.
+func (p *Parser) synchronize(depth int) {
+	for !p.peekTokenIs(token.EOF) {
+		if p.depth <= depth {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+			switch p.peekToken.Type {
+			case token.RBRACE, token.LET, token.RETURN:
+				return
+			}
+		}
+		p.nextToken()
+	}
+}
+
+// parseStatement parses a statement and returns nil if it fails.
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		if stmt := p.parseLetStatement(); stmt != nil {
+			return stmt
+		}
+		return nil // not a typed nil
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
+	case token.TRY:
+		return p.parseTryStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
@@ -135,12 +292,28 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fn.Name = stmt.Name.Value
+	}
 	for p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
 }
 
+// parseExportStatement parses "export let name = value;".
+func (p *Parser) parseExportStatement() ast.Statement {
+	tok := p.curToken
+	if !p.expectPeek(token.LET) {
+		return nil
+	}
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+	return &ast.ExportStatement{Token: tok, Statement: let}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -151,6 +324,54 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() ast.Statement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseTryStatement parses "try {...} catch (e) {...} finally {...}" where one of the clauses may be omitted.
+func (p *Parser) parseTryStatement() ast.Statement {
+	stmt := &ast.TryStatement{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Block = p.parseBlockStatement()
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		stmt.Finally = p.parseBlockStatement()
+	}
+	if stmt.Catch == nil && stmt.Finally == nil {
+		p.peekError(token.CATCH)
+		return nil
+	}
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
@@ -163,19 +384,27 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix, ok := p.prefixParseFns[p.curToken.Type]
 	if !ok {
-		msg := fmt.Errorf("%d:%d no prefix parse function for %s found (%w)", p.curToken.Row, p.curToken.Col, p.curToken.Type, ErrNoParseFunc)
-		p.errs = append(p.errs, msg)
+		// an illegal token the lexer has already reported
+		if p.curTokenIs(token.ILLEGAL) && p.curLexErr {
+			return nil
+		}
+		p.tokenError(p.curToken, ErrNoParseFunc, "no prefix parse function for %s found", p.curToken.Type)
 		return nil
 	}
 
 	leftExpr := prefix()
+	if leftExpr == nil {
+		return nil // already reported
+	}
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix, ok := p.infixParseFns[p.peekToken.Type]
 		if !ok {
 			return leftExpr
 		}
 		p.nextToken()
-		leftExpr = infix(leftExpr)
+		if leftExpr = infix(leftExpr); leftExpr == nil {
+			return nil // already reported
+		}
 	}
 
 	return leftExpr
@@ -187,7 +416,9 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 		Operator: p.curToken.Literal,
 	}
 	p.nextToken()
-	expr.Right = p.parseExpression(PREFIX)
+	if expr.Right = p.parseExpression(PREFIX); expr.Right == nil {
+		return nil // already reported
+	}
 	return expr
 }
 
@@ -199,8 +430,18 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		err := fmt.Errorf("%d:%d could not parse \"%s\" as integer (%w)", p.curToken.Row, p.curToken.Col, p.curToken.Literal, ErrInvalidLiteral)
-		p.errs = append(p.errs, err)
+		p.tokenError(p.curToken, ErrInvalidLiteral, "could not parse \"%s\" as integer", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.tokenError(p.curToken, ErrInvalidLiteral, "could not parse \"%s\" as float", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
@@ -213,8 +454,9 @@ func (p *Parser) parseBooleanLiteral() ast.Expression {
 
 func (p *Parser) parseGroupedExpression() ast.Expression {
 	p.nextToken()
+	numErrs := len(p.errs)
 	expr := p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RPAREN) {
+	if len(p.errs) > numErrs || !p.expectPeek(token.RPAREN) { // the missing ")" is not reported after errors in expr
 		return nil
 	}
 	return expr
@@ -245,11 +487,85 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expr
 }
 
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	for p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+// parseLoopControlStatement parses break or continue, which must be in a loop of the current function.
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if p.loopDepth == 0 {
+		p.tokenError(tok, ErrOutsideLoop, "%s outside loop", tok.Literal)
+		return nil
+	}
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	bs := &ast.BlockStatement{Token: p.curToken}
+	depth := p.depth
 	p.nextToken()
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementOrSync(depth)
+		if export, ok := stmt.(*ast.ExportStatement); ok {
+			p.tokenError(export.Token, ErrNotTopLevel, "export must be at the top level")
+			stmt = nil
+		}
 		if stmt != nil {
 			bs.Statements = append(bs.Statements, stmt)
 		}
@@ -263,27 +579,85 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
-	fn.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(fn) {
+		return nil
+	}
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	fn.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 	return fn
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	var idents []*ast.Identifier
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	tok := p.curToken
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	fn := &ast.FunctionLiteral{}
+	if !p.parseFunctionParameters(fn) {
+		return nil
+	}
+	if fn.Defaults != nil || fn.Rest != nil {
+		p.tokenError(tok, ErrInvalidParam, "macro cannot have default or rest parameters")
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	body := p.parseBlockStatement()
+	p.loopDepth = loopDepth
+	return &ast.MacroLiteral{Token: tok, Parameters: fn.Parameters, Body: body}
+}
+
+// parseFunctionParameters parses parameters like "a, b = 1, ...c" and sets them to fn.
+func (p *Parser) parseFunctionParameters(fn *ast.FunctionLiteral) bool {
+	hasDefault := false
 	for !p.peekTokenIs(token.RPAREN) {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				return false
+			}
+			fn.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.peekTokenIs(token.RPAREN) {
+				p.tokenError(p.curToken, ErrInvalidParam, "rest parameter %s must be the last parameter", fn.Rest.Value)
+				return false
+			}
+			break
+		}
 		if !p.expectPeek(token.IDENT) {
-			return nil
+			return false
 		}
-		idents = append(idents, &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal})
-		if p.peekTokenIs(token.COMMA) {
+		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		var def ast.Expression
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
 			p.nextToken()
+			if def = p.parseExpression(LOWEST); def == nil {
+				return false
+			}
+			hasDefault = true
+		} else if hasDefault {
+			p.tokenError(ident.Token, ErrInvalidParam, "parameter %s without default value follows parameter with default value", ident.Value)
+			return false
+		}
+		fn.Parameters = append(fn.Parameters, ident)
+		fn.Defaults = append(fn.Defaults, def)
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return false
 		}
 	}
+	if !hasDefault {
+		fn.Defaults = nil
+	}
 	p.nextToken() // skip RPAREN
-	return idents
+	return true
 }
 
 func (p *Parser) parseInfixExpression(leftExpr ast.Expression) ast.Expression {
@@ -294,7 +668,31 @@ func (p *Parser) parseInfixExpression(leftExpr ast.Expression) ast.Expression {
 	}
 	prec := p.curPrecedence()
 	p.nextToken()
-	expr.Right = p.parseExpression(prec)
+	if expr.Right = p.parseExpression(prec); expr.Right == nil {
+		return nil // already reported
+	}
+	return expr
+}
+
+// parseAssignExpression parses the right-associative assignment to an identifier or an index expression.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expr := &ast.AssignExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Target:   target,
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	case nil:
+		return nil // already reported
+	default:
+		p.tokenError(p.curToken, ErrInvalidAssign, "cannot assign to %s", target)
+		return nil
+	}
+	p.nextToken()
+	if expr.Value = p.parseExpression(ASSIGN - 1); expr.Value == nil {
+		return nil // already reported
+	}
 	return expr
 }
 
@@ -313,8 +711,33 @@ func (p *Parser) parseIndexExpression(leftExpr ast.Expression) ast.Expression {
 		Left:  leftExpr,
 	}
 	p.nextToken()
+	numErrs := len(p.errs)
 	expr.Index = p.parseExpression(LOWEST)
-	if !p.expectPeek(token.RBRACKET) {
+	if len(p.errs) > numErrs || !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return expr
+}
+
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	expr := &ast.MemberExpression{Token: p.curToken, Object: object}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	expr.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return expr
+}
+
+func (p *Parser) parseImportExpression() ast.Expression {
+	expr := &ast.ImportExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	if expr.Path = p.parseExpression(LOWEST); expr.Path == nil {
+		return nil
+	}
+	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 	return expr
@@ -330,6 +753,25 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	return arr
 }
 
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken() // skip RBRACE
+	return hash
+}
+
 func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 	var args []ast.Expression
 	for !p.peekTokenIs(end) {
@@ -339,8 +781,8 @@ func (p *Parser) parseExpressionList(end token.Type) []ast.Expression {
 			return nil
 		}
 		args = append(args, expr)
-		if p.peekTokenIs(token.COMMA) {
-			p.nextToken()
+		if !p.peekTokenIs(end) && !p.expectPeek(token.COMMA) {
+			return nil
 		}
 	}
 	p.nextToken() // skip end token
@@ -371,8 +813,7 @@ func (p *Parser) expectPeek(t token.Type) bool {
 }
 
 func (p *Parser) peekError(t token.Type) {
-	err := fmt.Errorf("%d:%d expected \"%s\" but got \"%s\" instead (%w)", p.peekToken.Row, p.peekToken.Col, t, p.peekToken.Type, ErrTokenType)
-	p.errs = append(p.errs, err)
+	p.tokenError(p.peekToken, ErrTokenType, "expected \"%s\" but got \"%s\" instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) registerPrefix(tokenType token.Type, fn prefixParseFn) {
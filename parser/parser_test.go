@@ -1,7 +1,9 @@
 package parser_test
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/ebiiim/monkey/ast"
@@ -110,7 +112,7 @@ func TestLetStatementErr(t *testing.T) {
 let = 10;
 let foobar = 123456;
 `,
-			5, 2,
+			2, 1,
 		},
 	}
 	for _, c := range cases {
@@ -241,6 +243,45 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	cases := []struct {
+		input string
+		want  float64
+	}{
+		{"1.5", 1.5},
+		{"0.15;", 0.15},
+		{"1e3", 1000},
+		{"2.5E-3", 0.0025},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			exprStmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement but %T", program.Statements[0])
+			}
+			lit, ok := exprStmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("expression is not *ast.FloatLiteral but %T", exprStmt.Expression)
+			}
+			if lit.Value != c.want {
+				t.Errorf("wrong value want=%v got=%v", c.want, lit.Value)
+			}
+			if lit.String() != strings.TrimSuffix(c.input, ";") {
+				t.Errorf("wrong String() want=%s got=%s", c.input, lit.String())
+			}
+		})
+	}
+	p := parser.New(lexer.New("1e999"))
+	p.ParseProgram()
+	if len(p.Errors()) != 1 || !errors.Is(p.Errors()[0], parser.ErrInvalidLiteral) {
+		t.Errorf("want %v got=%v", parser.ErrInvalidLiteral, p.Errors())
+	}
+}
+
 func TestParsingPrefixExpression(t *testing.T) {
 	cases := []struct {
 		name          string
@@ -349,6 +390,14 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		{"false", "false"},
 		{"3 > 5 == false", "((3 > 5) == false)"},
 		{"3 < 5 == true", "((3 < 5) == true)"},
+		// comparison and logical
+		{"a <= b == b >= a", "((a <= b) == (b >= a))"},
+		{"a + b % c * d", "(a + ((b % c) * d))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c", "((a && b) || c)"},
+		{"a < b && b < c == true", "((a < b) && ((b < c) == true))"},
+		{"!a && b", "((!a) && b)"},
+		{"x = a || b", "(x = (a || b))"},
 		// grouped
 		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
 		{"(5 + 5) * 2", "((5 + 5) * 2)"},
@@ -530,6 +579,78 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultAndRestParameterParsing(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"fn(a, b = 2) {}", "fn (a, b = 2) "},
+		{"fn(a = 1, b = a * 2) { a + b }", "fn (a = 1, b = (a * 2)) (a + b)"},
+		{"fn(...rest) {}", "fn (...rest) "},
+		{"fn(a, b = 2, ...rest) { rest }", "fn (a, b = 2, ...rest) rest"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if got := program.String(); got != c.want {
+				t.Errorf("want=%q got=%q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestFunctionParameterParsingErr(t *testing.T) {
+	cases := []struct {
+		input string
+		want  error
+	}{
+		{"fn(a = 1, b) {}", parser.ErrInvalidParam},
+		{"fn(...a, b) {}", parser.ErrInvalidParam},
+		{"fn(...a = 1) {}", parser.ErrInvalidParam},
+		{"fn(...) {}", parser.ErrTokenType},
+		{"fn(a = , b) {}", parser.ErrNoParseFunc},
+		{"fn(a b) {}", parser.ErrTokenType},
+		{"fn(a, b c) {}", parser.ErrTokenType},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatal("no errors")
+			}
+			if !errors.Is(p.Errors()[0], c.want) {
+				t.Errorf("want=%v got=%v", c.want, p.Errors()[0])
+			}
+		})
+	}
+}
+
+func TestFunctionLiteralWithName(t *testing.T) {
+	input := `let myFunction = fn() { };`
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserError(t, p, program)
+	if len(program.Statements) != 1 {
+		t.Fatalf("len(program.Statements) want=1 got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.LetStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.LetStatement but %T", program.Statements[0])
+	}
+	fn, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not *ast.FunctionLiteral but %T", stmt.Value)
+	}
+	if fn.Name != "myFunction" {
+		t.Errorf("fn.Name want=myFunction got=%s", fn.Name)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := `add(1, 2 * 3, 4 + 5);`
 	p := parser.New(lexer.New(input))
@@ -623,6 +744,394 @@ func TestParsingIndexExpressions(t *testing.T) {
 	testInfixExpression(t, expr.Index, "+", 1, 1)
 }
 
+func TestParsingHashLiteral(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+		size  int
+	}{
+		{`{}`, `{}`, 0},
+		{`{"one": 1, "two": 2, "three": 3}`, `{one: 1, two: 2, three: 3}`, 3},
+		{`{1: true, true: "yes"}`, `{1: true, true: yes}`, 2},
+		{`{"one": 0 + 1, "two": 10 - 8, "three": 15 / 5}`, `{one: (0 + 1), two: (10 - 8), three: (15 / 5)}`, 3},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if len(program.Statements) != 1 {
+				t.Fatalf("len(program.Statements) want=1 got=%d", len(program.Statements))
+			}
+			stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("program.Statements[0] is not *ast.ExpressionStatement but %T", program.Statements[0])
+			}
+			expr, ok := stmt.Expression.(*ast.HashLiteral)
+			if !ok {
+				t.Fatalf("expr is not *ast.HashLiteral but %T", stmt.Expression)
+			}
+			if len(expr.Pairs) != c.size {
+				t.Fatalf("len(expr.Pairs) want=%d got=%d", c.size, len(expr.Pairs))
+			}
+			if expr.String() != c.want {
+				t.Errorf("expr.String() want=%s got=%s", c.want, expr.String())
+			}
+		})
+	}
+}
+
+func TestParsingHashLiteralErr(t *testing.T) {
+	cases := []string{
+		`{"one" 1}`,
+		`{"one": 1 "two": 2}`,
+		`{"one": 1`,
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c, func(t *testing.T) {
+			p := parser.New(lexer.New(c))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatal("no errors")
+			}
+		})
+	}
+}
+
+func TestAssignExpressionParsing(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 5", "(x = (y = 5))"},
+		{"x += 1 + 2 * 3", "(x += (1 + (2 * 3)))"},
+		{"x -= 1", "(x -= 1)"},
+		{"x *= f(1)", "(x *= f(1))"},
+		{"x /= 2", "(x /= 2)"},
+		{"a[i + 1] = b[0]", "((a[(i + 1)]) = (b[0]))"},
+		{`h["k"] += 1`, "((h[k]) += 1)"},
+		{"x = fn(a) { a = a + 1 }", "(x = fn (a) (a = (a + 1)))"},
+		{"f(x = 1)", "f((x = 1))"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if program.String() != c.want {
+				t.Errorf("want=%s got=%s", c.want, program.String())
+			}
+		})
+	}
+}
+
+func TestAssignExpressionParsingErr(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"1 = 2", "1:3 cannot assign to 1 (ErrInvalidAssign)"},
+		{"a + b = 2", "1:7 cannot assign to (a + b) (ErrInvalidAssign)"},
+		{"f() += 2", "1:5 cannot assign to f() (ErrInvalidAssign)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatal("no errors")
+			}
+			err := p.Errors()[0]
+			if !errors.Is(err, parser.ErrInvalidAssign) {
+				t.Errorf("wrong error type want=%v got=%v", parser.ErrInvalidAssign, err)
+			}
+			if err.Error() != c.want {
+				t.Errorf("wrong error message want=%s got=%s", c.want, err)
+			}
+		})
+	}
+}
+
+func TestLoopStatementParsing(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"while (x < 10) { x += 1 }", "while(x < 10) (x += 1)"},
+		{"for (x in [1, 2]) { puts(x); }", "for (x in [1, 2]) puts(x)"},
+		{"for (x in range(3)) { if (x == 1) { continue; } break; }", "for (x in range(3)) if(x == 1) continuebreak"},
+		{"while (true) { let f = fn() { for (a in b) { break } } }", "whiletrue let f = fn () for (a in b) break;"},
+		{"while (i < 3) { i += 1 }; i", "while(i < 3) (i += 1)i"},
+		{"for (x in a) { x };", "for (x in a) x"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if program.String() != c.want {
+				t.Errorf("want=%s got=%s", c.want, program.String())
+			}
+		})
+	}
+	p := parser.New(lexer.New("for (item in items) { item }"))
+	program := p.ParseProgram()
+	checkParserError(t, p, program)
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.ForStatement but %T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") || !testIdentifier(t, stmt.Iterable, "items") {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Errorf("len(stmt.Body.Statements) want=1 got=%d", len(stmt.Body.Statements))
+	}
+}
+
+func TestLoopStatementParsingErr(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"break;", parser.ErrOutsideLoop, "1:1 break outside loop (ErrOutsideLoop)"},
+		{"if (x) { continue }", parser.ErrOutsideLoop, "1:10 continue outside loop (ErrOutsideLoop)"},
+		{"while (x) { fn() { break } }", parser.ErrOutsideLoop, "1:20 break outside loop (ErrOutsideLoop)"},
+		{"for (1 in x) { }", parser.ErrTokenType, `1:6 expected "IDENT" but got "INT" instead (ErrTokenType)`},
+		{"for (x of y) { }", parser.ErrTokenType, `1:8 expected "in" but got "IDENT" instead (ErrTokenType)`},
+		{"while x { }", parser.ErrTokenType, `1:7 expected "(" but got "IDENT" instead (ErrTokenType)`},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatal("no errors")
+			}
+			err := p.Errors()[0]
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("wrong error type want=%v got=%v", c.wantErr, err)
+			}
+			if err.Error() != c.wantMsg {
+				t.Errorf("wrong error message want=%s got=%s", c.wantMsg, err)
+			}
+		})
+	}
+}
+
+func TestModuleParsing(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{`let m = import("lib");`, "let m = import(lib);"},
+		{`import("./a" + name).f(1)`, "(import((./a + name)).f)(1)"},
+		{"a.b.c", "((a.b).c)"},
+		{"a.b[0] + -c.d", "(((a.b)[0]) + (-(c.d)))"},
+		{"export let x = 1;", "export let x = 1;"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if got := program.String(); got != c.want {
+				t.Errorf("want=%s, got=%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestModuleParsingErr(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"fn() { export let x = 1; }", parser.ErrNotTopLevel, "1:8 export must be at the top level (ErrNotTopLevel)"},
+		{"export x = 1;", parser.ErrTokenType, `1:8 expected "let" but got "IDENT" instead (ErrTokenType)`},
+		{"a.1", parser.ErrTokenType, `1:3 expected "IDENT" but got "INT" instead (ErrTokenType)`},
+		{`import "lib"`, parser.ErrTokenType, `1:8 expected "(" but got "STRING" instead (ErrTokenType)`},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatalf("no error")
+			}
+			err := p.Errors()[0]
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, err)
+			}
+			if err.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestMacroLiteralParsing(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"let m = macro(x, y) { x + y; };", "let m = macro(x, y) (x + y);"},
+		{"macro() { quote(1) }", "macro() quote(1)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if got := program.String(); got != c.want {
+				t.Errorf("want=%s, got=%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestMacroLiteralParsingErr(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"macro(x = 1) { x }", parser.ErrInvalidParam, "1:1 macro cannot have default or rest parameters (ErrInvalidParam)"},
+		{"macro(...xs) { xs }", parser.ErrInvalidParam, "1:1 macro cannot have default or rest parameters (ErrInvalidParam)"},
+		{"macro { 1 }", parser.ErrTokenType, `1:7 expected "(" but got "{" instead (ErrTokenType)`},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatalf("no error")
+			}
+			err := p.Errors()[0]
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, err)
+			}
+			if err.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestTryStatementParsing(t *testing.T) {
+	cases := []struct {
+		input, want string
+	}{
+		{"try { f() } catch (e) { g(e) }", "try f() catch (e) g(e)"},
+		{"try { f() } finally { g() }", "try f() finally g()"},
+		{"try { f() } catch (e) { g(e) } finally { h() }", "try f() catch (e) g(e) finally h()"},
+		{"try { 1 } catch (e) { 2 }; 3", "try 1 catch (e) 23"},
+		{"try { 1 } finally { 2 };", "try 1 finally 2"},
+		{`throw "boom";`, "throw boom"},
+		{"throw error(x + 1)", "throw error((x + 1))"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			checkParserError(t, p, program)
+			if got := program.String(); got != c.want {
+				t.Errorf("want=%s, got=%s", c.want, got)
+			}
+		})
+	}
+}
+
+func TestTryStatementParsingErr(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+		wantMsg string
+	}{
+		{"try { f() }", parser.ErrTokenType, `1:12 expected "catch" but got "EOF" instead (ErrTokenType)`},
+		{"try f()", parser.ErrTokenType, `1:5 expected "{" but got "IDENT" instead (ErrTokenType)`},
+		{"try { f() } catch { g() }", parser.ErrTokenType, `1:19 expected "(" but got "{" instead (ErrTokenType)`},
+		{"try { f() } catch (1) { g() }", parser.ErrTokenType, `1:20 expected "IDENT" but got "INT" instead (ErrTokenType)`},
+		{"throw;", parser.ErrNoParseFunc, "1:6 no prefix parse function for ; found (ErrNoParseFunc)"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) == 0 {
+				t.Fatalf("no error")
+			}
+			err := p.Errors()[0]
+			if !errors.Is(err, c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, err)
+			}
+			if err.Error() != c.wantMsg {
+				t.Errorf("wrong message want=%q got=%q", c.wantMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add two values
+let add = fn(a, b) {
+	a + b; /* sum */
+};`
+	p := parser.New(lexer.NewWithComments(input))
+	program := p.ParseProgram()
+	checkParserError(t, p, program)
+	if want := "let add = fn (a, b) (a + b);"; program.String() != want {
+		t.Errorf("program.String() want=%s got=%s", want, program.String())
+	}
+	want := []token.Token{
+		token.New(token.COMMENT, "// add two values", 1, 1),
+		token.New(token.COMMENT, "/* sum */", 3, 12),
+	}
+	if len(p.Comments()) != len(want) {
+		t.Fatalf("len(p.Comments()) want=%d got=%d", len(want), len(p.Comments()))
+	}
+	for i, w := range want {
+		if p.Comments()[i] != w {
+			t.Errorf("comment#%d want=%+v got=%+v", i, w, p.Comments()[i])
+		}
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	cases := []struct {
+		input   string
+		wantErr error
+	}{
+		{"let a = 1; /* unterminated", lexer.ErrUnterminatedComment},
+		{`let a = "unterminated`, lexer.ErrUnterminatedString},
+		{`let a = "\q";`, lexer.ErrInvalidEscape},
+		{`let a = 1e;`, lexer.ErrInvalidNumber},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			if len(p.Errors()) != 1 {
+				t.Fatalf("len(p.Errors()) want=1 got=%d (%v)", len(p.Errors()), p.Errors())
+			}
+			if !errors.Is(p.Errors()[0], c.wantErr) {
+				t.Errorf("want=%v got=%v", c.wantErr, p.Errors()[0])
+			}
+		})
+	}
+}
+
 // testInfixExpression tests if expr has an operator and two literals.
 func testInfixExpression(t *testing.T, expr ast.Expression, op string, left, right interface{}) bool {
 	t.Helper()
@@ -732,3 +1241,121 @@ func expectNoError(t *testing.T, errs []error) {
 		t.Fatalf("got %d errors", len(errs))
 	}
 }
+
+func TestErr(t *testing.T) {
+	p := parser.New(lexer.New("let a = 1;"))
+	p.ParseProgram()
+	if err := p.Err(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	p = parser.New(lexer.New("let = 1;\nlet b 2;\n)"))
+	p.ParseProgram()
+	err := p.Err()
+	if !errors.Is(err, parser.ErrTokenType) || !errors.Is(err, parser.ErrNoParseFunc) || errors.Is(err, parser.ErrInvalidParam) {
+		t.Errorf("wrong errors.Is result for %v", err)
+	}
+	want := `1:5 expected "IDENT" but got "=" instead (ErrTokenType)
+2:7 expected "=" but got "INT" instead (ErrTokenType)
+3:1 no prefix parse function for ) found (ErrNoParseFunc)`
+	if err.Error() != want {
+		t.Errorf("wrong message want=%q got=%q", want, err.Error())
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string // errors
+		stmts string   // the statements parsed
+	}{
+		{"let = 1; let x = 2;", []string{`1:5 expected "IDENT" but got "=" instead (ErrTokenType)`}, "let x = 2;"},
+		{"let = 1\nlet x = 2", []string{`1:5 expected "IDENT" but got "=" instead (ErrTokenType)`}, "let x = 2;"},
+		{"f(a b c); g()", []string{`1:5 expected "," but got "IDENT" instead (ErrTokenType)`}, "f()g()"},
+		{"[1 2, 3]; x", []string{`1:4 expected "," but got "INT" instead (ErrTokenType)`}, "[]x"},
+		{"let f = fn(a b) { x; y }; f()", []string{`1:14 expected "," but got "IDENT" instead (ErrTokenType)`}, "let f = ;f()"},
+		{"let f = fn() { let = 1; 2 }; f()", []string{`1:20 expected "IDENT" but got "=" instead (ErrTokenType)`}, "let f = fn () 2;f()"},
+		{"if (x) { let = 1 }\nputs(1)", []string{`1:14 expected "IDENT" but got "=" instead (ErrTokenType)`}, "ifx puts(1)"},
+		{"fn() { (1 + ) }; 3", []string{"1:13 no prefix parse function for ) found (ErrNoParseFunc)"}, "fn () 3"},
+		{")", []string{"1:1 no prefix parse function for ) found (ErrNoParseFunc)"}, ""},
+		{"(1))", []string{"1:4 no prefix parse function for ) found (ErrNoParseFunc)"}, "1"},
+		{"x[]; y", []string{"1:3 no prefix parse function for ] found (ErrNoParseFunc)"}, "y"},
+		{"puts(1 +)\nlet y = 2;", []string{"1:9 no prefix parse function for ) found (ErrNoParseFunc)"}, "puts()let y = 2;"},
+		{"-", []string{"1:2 no prefix parse function for EOF found (ErrNoParseFunc)"}, ""},
+		{"x = ; y", []string{"1:5 no prefix parse function for ; found (ErrNoParseFunc)"}, "y"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			program := p.ParseProgram()
+			var got []string
+			for _, err := range p.Errors() {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(c.want, "\n") {
+				t.Errorf("errors want=%q got=%q", c.want, got)
+			}
+			for _, stmt := range program.Statements {
+				if stmt == nil {
+					t.Fatal("nil statement")
+				}
+				if let, ok := stmt.(*ast.LetStatement); ok && let == nil {
+					t.Fatal("typed nil statement")
+				}
+			}
+			if program.String() != c.stmts {
+				t.Errorf("statements want=%q got=%q", c.stmts, program.String())
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []parser.Diagnostic
+	}{
+		{"let x = 1;", nil},
+		{"let = 1;", []parser.Diagnostic{
+			{Start: parser.Position{Row: 1, Col: 5}, End: parser.Position{Row: 1, Col: 6}, Severity: parser.SeverityError, Code: "ErrTokenType", Message: `expected "IDENT" but got "=" instead`},
+		}},
+		{"f(abc def)", []parser.Diagnostic{
+			{Start: parser.Position{Row: 1, Col: 7}, End: parser.Position{Row: 1, Col: 10}, Severity: parser.SeverityError, Code: "ErrTokenType", Message: `expected "," but got "IDENT" instead`},
+		}},
+		{"x = 99999999999999999999", []parser.Diagnostic{
+			{Start: parser.Position{Row: 1, Col: 5}, End: parser.Position{Row: 1, Col: 25}, Severity: parser.SeverityError, Code: "ErrInvalidLiteral", Message: `could not parse "99999999999999999999" as integer`},
+		}},
+		{"f(\n", []parser.Diagnostic{
+			{Start: parser.Position{Row: 2, Col: 1}, End: parser.Position{Row: 2, Col: 1}, Severity: parser.SeverityError, Code: "ErrNoParseFunc", Message: "no prefix parse function for EOF found"},
+		}},
+		{`let s = "a\qb";`, []parser.Diagnostic{
+			{Start: parser.Position{Row: 1, Col: 11}, End: parser.Position{Row: 1, Col: 13}, Severity: parser.SeverityError, Code: "ErrInvalidEscape", Message: `invalid escape sequence \q`},
+		}},
+		{`let "a\tb" = 1;`, []parser.Diagnostic{
+			{Start: parser.Position{Row: 1, Col: 5}, End: parser.Position{Row: 1, Col: 11}, Severity: parser.SeverityError, Code: "ErrTokenType", Message: `expected "IDENT" but got "STRING" instead`},
+		}},
+		{"while (x) { break }\nbreak", []parser.Diagnostic{
+			{Start: parser.Position{Row: 2, Col: 1}, End: parser.Position{Row: 2, Col: 6}, Severity: parser.SeverityError, Code: "ErrOutsideLoop", Message: "break outside loop"},
+		}},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			p := parser.New(lexer.New(c.input))
+			p.ParseProgram()
+			got := p.Diagnostics()
+			if len(got) != len(c.want) {
+				t.Fatalf("want=%+v got=%+v", c.want, got)
+			}
+			for i, d := range got {
+				if d.Err != p.Errors()[i] {
+					t.Errorf("Err want=%v got=%v", p.Errors()[i], d.Err)
+				}
+				d.Err = nil
+				if d != c.want[i] {
+					t.Errorf("want=%+v got=%+v", c.want[i], d)
+				}
+			}
+		})
+	}
+}
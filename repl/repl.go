@@ -2,47 +2,230 @@ package repl
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strings"
 
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/compiler"
+	"github.com/ebiiim/monkey/diag"
 	"github.com/ebiiim/monkey/evaluator"
 	"github.com/ebiiim/monkey/lexer"
 	"github.com/ebiiim/monkey/object"
 	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/token"
+	"github.com/ebiiim/monkey/vm"
 )
 
 // PROMPT is the prompt text used in the REPL.
 const PROMPT = ">> "
 
-// Start starts a REPL.
+// CONT_PROMPT is the prompt text used while the input is incomplete.
+const CONT_PROMPT = ".. "
+
+// Engines that run programs.
+const (
+	EngineEval = "eval" // tree-walking evaluator
+	EngineVM   = "vm"   // bytecode compiler and VM
+)
+
+// VMLimits lists the features that EngineVM rejects with compiler.ErrUnsupportedNode or, for the prelude, evaluator.ErrIdentifierNotFound.
+const VMLimits = "floats, assignments, loops, try/throw, modules, macros, default and rest parameters, or the prelude"
+
+// Config configures the REPL.
+type Config struct {
+	// Engine is EngineEval or EngineVM, which does not support VMLimits.
+	Engine string
+	// CheckedArithmetic reports integer overflow as an error (EngineEval only).
+	CheckedArithmetic bool
+	// Dir is the directory that relative imports are resolved against (EngineEval only).
+	Dir string
+	// File is the name of the file of the program in Dir, which is not imported again (EngineEval only).
+	File string
+	// ModulePath lists the directories searched for other imports (EngineEval only).
+	ModulePath []string
+	// NoPrelude starts without the prelude (EngineEval only).
+	NoPrelude bool
+}
+
+// Start starts a REPL with the evaluator.
 func Start(in io.Reader, out io.Writer) {
+	StartWithConfig(in, out, Config{Engine: EngineEval})
+}
+
+// StartWithConfig starts a REPL.
+// Incomplete input is buffered with CONT_PROMPT until it is complete or canceled with :cancel.
+func StartWithConfig(in io.Reader, out io.Writer, cfg Config) {
 	sc := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+	engine := NewEngine(cfg)
+	var buf strings.Builder
+	inputs := 0
 	for {
-		fmt.Fprint(out, PROMPT)
+		if buf.Len() == 0 {
+			fmt.Fprint(out, PROMPT)
+		} else {
+			fmt.Fprint(out, CONT_PROMPT)
+		}
 		if ok := sc.Scan(); !ok {
 			return
 		}
-		p := parser.New(lexer.New(catchREPLCommands(out, sc.Text())))
+		line := sc.Text()
+		if strings.TrimSpace(line) == ":cancel" {
+			buf.Reset()
+			continue
+		}
+		buf.WriteString(catchREPLCommands(out, line))
+		buf.WriteString("\n")
+		if IsIncomplete(buf.String()) {
+			continue
+		}
+		src := buf.String()
+		buf.Reset()
+		p := parser.New(lexer.New(src))
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+			printParserErrors(out, src, p.Diagnostics())
 			continue
 		}
-		ev := evaluator.Eval(program, env)
-		if ev != nil {
+		inputs++
+		file := ""
+		if e, ok := engine.(*evalEngine); ok {
+			// errors in functions defined by earlier inputs are not in src
+			file = fmt.Sprintf("<input %d>", inputs)
+			e.ev.SetFile(file)
+		}
+		ev := engine.Run(program)
+		if errObj, ok := ev.(*object.Error); ok {
+			printError(out, src, file, errObj)
+		} else if ev != nil {
 			fmt.Fprintf(out, "%s\n", ev.Inspect())
 		}
 	}
 }
 
-func printParserErrors(out io.Writer, errors []error) {
-	for _, msg := range errors {
-		fmt.Fprintf(out, "\t%s\n", msg)
+// IsIncomplete reports whether more input is needed to complete src:
+// brackets are unbalanced, a block comment or a string is unterminated, or it ends with an operator or a keyword that needs an operand.
+func IsIncomplete(src string) bool {
+	l := lexer.New(src)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE, token.LBRACKET:
+			depth++
+		case token.RPAREN, token.RBRACE, token.RBRACKET:
+			depth--
+		}
+		last = tok
+	}
+	for _, err := range l.Errors() {
+		if errors.Is(err, lexer.ErrUnterminatedComment) || errors.Is(err, lexer.ErrUnterminatedString) {
+			return true
+		}
+	}
+	if depth > 0 {
+		return true
 	}
+	switch last.Type {
+	case token.ASSIGN, token.PLUS, token.MINUS, token.BANG, token.ASTERISK, token.SLASH,
+		token.PERCENT, token.LT, token.GT, token.LTE, token.GTE, token.EQ, token.NEQ, token.AND, token.OR,
+		token.COMMA, token.COLON, token.ELLIPSIS, token.DOT,
+		token.PLUS_ASSIGN, token.MINUS_ASSIGN, token.ASTERISK_ASSIGN, token.SLASH_ASSIGN,
+		token.FUNCTION, token.LET, token.IF, token.ELSE, token.WHILE, token.FOR, token.IN, token.IMPORT, token.EXPORT, token.MACRO,
+		token.TRY, token.CATCH, token.FINALLY, token.THROW:
+		return true
+	}
+	return false
+}
+
+// Engine runs programs and keeps its state between runs.
+type Engine interface {
+	// Run runs the program and returns the result.
+	// Errors are returned as *object.Error.
+	Run(program *ast.Program) object.Object
+	// Define binds val to name in the global scope.
+	Define(name string, val object.Object)
+}
+
+// NewEngine initializes an Engine by cfg.Engine. It falls back to EngineEval.
+func NewEngine(cfg Config) Engine {
+	switch cfg.Engine {
+	case EngineVM:
+		return &vmEngine{
+			symbols: compiler.NewSymbolTable(),
+			globals: make([]object.Object, vm.GlobalsSize),
+		}
+	default:
+		ev := evaluator.New(evaluator.Options{
+			CheckedArithmetic: cfg.CheckedArithmetic,
+			Dir:               cfg.Dir,
+			File:              cfg.File,
+			ModulePath:        cfg.ModulePath,
+			NoPrelude:         cfg.NoPrelude,
+		})
+		return &evalEngine{ev: ev, env: ev.NewEnvironment(), macros: object.NewEnvironment()}
+	}
+}
+
+type evalEngine struct {
+	ev     *evaluator.Evaluator
+	env    *object.Environment
+	macros *object.Environment
+}
+
+func (e *evalEngine) Run(program *ast.Program) object.Object {
+	evaluator.DefineMacros(program, e.macros)
+	expanded, err := e.ev.ExpandMacros(program, e.macros)
+	if err != nil {
+		return err.(*object.Error)
+	}
+	return e.ev.Eval(expanded, e.env)
+}
+
+func (e *evalEngine) Define(name string, val object.Object) {
+	e.env.Set(name, val)
+}
+
+type vmEngine struct {
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	globals   []object.Object
+}
+
+func (e *vmEngine) Run(program *ast.Program) object.Object {
+	comp := compiler.NewWithState(e.symbols, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err}
+	}
+	bc := comp.Bytecode()
+	e.constants = bc.Constants
+	machine := vm.NewWithGlobalsStore(bc, e.globals)
+	if err := machine.Run(); err != nil {
+		return &object.Error{Message: err}
+	}
+	return machine.LastPoppedStackElem()
+}
+
+func (e *vmEngine) Define(name string, val object.Object) {
+	sym := e.symbols.Define(name)
+	e.globals[sym.Index] = val
+}
+
+func printParserErrors(out io.Writer, src string, diags []parser.Diagnostic) {
+	for _, d := range diags {
+		diag.Render(out, src, diag.FromParser(d), diag.IsTerminal(out))
+	}
+}
+
+// printError writes errObj with a snippet of src, the source named file, and its stack trace.
+func printError(out io.Writer, src, file string, errObj *object.Error) {
+	d := diag.FromError(errObj, file)
+	d.Header = "ERROR: " + d.Header
+	diag.Render(out, src, d, diag.IsTerminal(out))
+	fmt.Fprint(out, errObj.StackTrace())
 }
 
 func catchREPLCommands(out io.Writer, input string) string {
@@ -64,7 +247,7 @@ func catchREPLCommands(out io.Writer, input string) string {
 }
 
 func help(out io.Writer) string {
-	fmt.Fprint(out, "REPL Commands:\n\t[ :exit | :quit | :q ] Quit the interpreter.\n\t[ :load FILE | :l FILE ] Load a Monkey source file.\n")
+	fmt.Fprint(out, "REPL Commands:\n\t[ :exit | :quit | :q ] Quit the interpreter.\n\t[ :load FILE | :l FILE ] Load a Monkey source file.\n\t[ :cancel ] Discard the incomplete input.\n")
 	return ""
 }
 
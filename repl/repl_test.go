@@ -0,0 +1,73 @@
+package repl_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ebiiim/monkey/repl"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	cases := []struct {
+		input string
+		want  bool
+	}{
+		{"", false},
+		{"1 + 2", false},
+		{"let f = fn(x) {", true},
+		{"let f = fn(x) {\n x }", false},
+		{"[1, 2", true},
+		{"add(1,", true},
+		{"{\"a\":", true},
+		{"1 +", true},
+		{"let a =", true},
+		{"if (x) { 1 } else", true},
+		{"/* comment", true},
+		{"/* comment */", false},
+		{"1 // comment {", false},
+		{`"abc`, true},
+		{"m.", true},
+		{"export", true},
+		{`"a\n{"`, false},
+		{"}", false},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			if got := repl.IsIncomplete(c.input); got != c.want {
+				t.Errorf("want=%v got=%v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"complete", "1 + 2\n", ">> 3\n>> "},
+		{"function", "let add = fn(a, b) {\n  a +\n  b\n};\nadd(1, 2)\n", ">> .. .. .. >> 3\n>> "},
+		{"cancel", "let a = [1,\n:cancel\n5\n", ">> .. >> 5\n>> "},
+		{"error position", "let a = 1;\nlet f = fn() {\n  a + true\n};\nf()\n",
+			">> >> .. .. >> ERROR: 2:5 type mismatch: INTEGER + BOOLEAN\n 1 | f()\n   | ^ error in f\n\tat f (called at 1:1)\n>> "},
+		{"parse error", "let = 1;\n", ">> 1:5 expected \"IDENT\" but got \"=\" instead (ErrTokenType)\n 1 | let = 1;\n   |     ^\n>> "},
+	}
+	for _, engine := range []string{repl.EngineEval, repl.EngineVM} {
+		for _, c := range cases {
+			c := c
+			t.Run(engine+"/"+c.name, func(t *testing.T) {
+				if engine == repl.EngineVM && c.name == "error position" {
+					t.Skip("the VM does not record positions")
+				}
+				var out bytes.Buffer
+				repl.StartWithConfig(strings.NewReader(c.input), &out, repl.Config{Engine: engine})
+				if out.String() != c.want {
+					t.Errorf("want=%q got=%q", c.want, out.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Package script runs Monkey programs non-interactively.
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ebiiim/monkey/diag"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/repl"
+)
+
+// ArgsName is the name of the global that holds the script arguments.
+const ArgsName = "args"
+
+// StripShebang blanks out a leading "#!" line so that row numbers are kept.
+func StripShebang(src string) string {
+	if !strings.HasPrefix(src, "#!") {
+		return src
+	}
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		return src[i:]
+	}
+	return ""
+}
+
+// Run parses and runs src with the engine selected by cfg.
+// args is exposed to the program as an Array of Strings named ArgsName.
+// It returns a parser.ErrorList or an *object.Error on failure.
+func Run(src string, args []string, cfg repl.Config) (object.Object, error) {
+	p := parser.New(lexer.New(StripShebang(src)))
+	program := p.ParseProgram()
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	engine := repl.NewEngine(cfg)
+	elems := make([]object.Object, len(args))
+	for i, arg := range args {
+		elems[i] = &object.String{Value: arg}
+	}
+	engine.Define(ArgsName, &object.Array{Elements: elems})
+	result := engine.Run(program)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj
+	}
+	return result, nil
+}
+
+// PrintError writes err to w with every error prefixed by name, or by the file of a runtime error in a module.
+// Positioned errors are followed by a snippet of src, which is colored if w is a terminal,
+// and runtime errors by their stack trace.
+func PrintError(w io.Writer, name, src string, err error) {
+	color := diag.IsTerminal(w)
+	var parseErrs parser.ErrorList
+	if errors.As(err, &parseErrs) {
+		for _, e := range parseErrs {
+			d := diag.Diagnostic{Hint: diag.Hint(e)}
+			if pd, ok := parser.DiagnosticOf(e); ok {
+				d = diag.FromParser(pd)
+			}
+			d.Header = fmt.Sprintf("%s:%s", name, e)
+			diag.Render(w, src, d, color)
+		}
+		return
+	}
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		fmt.Fprintf(w, "%s: %s\n", name, err)
+		return
+	}
+	d := diag.FromError(errObj, "")
+	if errObj.File != "" {
+		name = errObj.File
+	}
+	if errObj.Row == 0 {
+		d.Header = fmt.Sprintf("%s: %s", name, d.Header)
+	} else {
+		d.Header = fmt.Sprintf("%s:%s", name, d.Header)
+	}
+	diag.Render(w, src, d, color)
+	fmt.Fprint(w, errObj.StackTrace())
+}
@@ -0,0 +1,134 @@
+package script_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/repl"
+	"github.com/ebiiim/monkey/script"
+)
+
+func TestStripShebang(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"1 + 2", "1 + 2"},
+		{"#!/usr/bin/env monkey", ""},
+		{"#!/usr/bin/env monkey\nputs(1);", "\nputs(1);"},
+		{"puts(1);\n#!/usr/bin/env monkey", "puts(1);\n#!/usr/bin/env monkey"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			if got := script.StripShebang(c.input); got != c.want {
+				t.Errorf("want=%q got=%q", c.want, got)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		args  []string
+		want  string
+	}{
+		{"simple", "1 + 2", nil, "3"},
+		{"shebang", "#!/usr/bin/env monkey\nlet a = 1;\na", nil, "1"},
+		{"no args", "args", nil, "[]"},
+		{"args", "len(args)", []string{"a", "b"}, "2"},
+		{"args elem", `args[1] + "!"`, []string{"a", "b"}, "b!"},
+	}
+	for _, engine := range []string{repl.EngineEval, repl.EngineVM} {
+		for _, c := range cases {
+			c := c
+			t.Run(engine+"/"+c.name, func(t *testing.T) {
+				got, err := script.Run(c.input, c.args, repl.Config{Engine: engine})
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if got.Inspect() != c.want {
+					t.Errorf("want=%s got=%s", c.want, got.Inspect())
+				}
+			})
+		}
+	}
+}
+
+func TestRunErr(t *testing.T) {
+	cases := []struct {
+		name      string
+		input     string
+		wantErr   error
+		wantPrint string
+	}{
+		{"parse", "#!/usr/bin/env monkey\nlet = 1;\nlet b 2;", parser.ErrTokenType,
+			"x.monkey:2:5 expected \"IDENT\" but got \"=\" instead (ErrTokenType)\n" +
+				" 2 | let = 1;\n" +
+				"   |     ^\n" +
+				"x.monkey:3:7 expected \"=\" but got \"INT\" instead (ErrTokenType)\n" +
+				" 3 | let b 2;\n" +
+				"   |       ^\n"},
+		{"runtime", "#!/usr/bin/env monkey\nlet f = fn() { 1 + true };\nf();", evaluator.ErrTypeMismatch,
+			"x.monkey:2:18 type mismatch: INTEGER + BOOLEAN\n" +
+				" 2 | let f = fn() { 1 + true };\n" +
+				"   |                  ^\n" +
+				"\tat f (called at 3:1)\n"},
+		{"top level", "let x = 1;\nx + foo;", evaluator.ErrIdentifierNotFound,
+			"x.monkey:2:5 identifier not found: foo\n" +
+				" 2 | x + foo;\n" +
+				"   |     ^~~\n" +
+				"   = hint: define it with let before it is used\n"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			_, err := script.Run(c.input, nil, repl.Config{})
+			if !errors.Is(err, c.wantErr) {
+				t.Fatalf("wrong error want=%v got=%v", c.wantErr, err)
+			}
+			var buf bytes.Buffer
+			script.PrintError(&buf, "x.monkey", c.input, err)
+			if buf.String() != c.wantPrint {
+				t.Errorf("wrong output want=%q got=%q", c.wantPrint, buf.String())
+			}
+		})
+	}
+	_, err := script.Run("foo", nil, repl.Config{})
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		t.Errorf("want *object.Error got=%T", err)
+	}
+}
+
+func TestPrintErrorInModule(t *testing.T) {
+	dir := t.TempDir()
+	mod := "let one = 1;\nexport let f = fn() {\n  one + true\n};\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "mod.monkey"), []byte(mod), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := "let m = import(\"./mod\");\nlet x = 1;\nm.f();\n"
+	_, err := script.Run(src, nil, repl.Config{Dir: dir})
+	if !errors.Is(err, evaluator.ErrTypeMismatch) {
+		t.Fatalf("wrong error want=%v got=%v", evaluator.ErrTypeMismatch, err)
+	}
+	var buf bytes.Buffer
+	script.PrintError(&buf, "main.monkey", src, err)
+	// the error is reported in mod.monkey and the call in main.monkey is marked
+	want := filepath.Join(dir, "mod.monkey") + ":3:7 type mismatch: INTEGER + BOOLEAN\n" +
+		" 3 | m.f();\n" +
+		"   |  ^ error in f\n" +
+		"\tat f (called at 3:2)\n"
+	if buf.String() != want {
+		t.Errorf("wrong output want=%q got=%q", want, buf.String())
+	}
+}
@@ -1,5 +1,7 @@
 package token
 
+import "sort"
+
 // Type represents token types.
 type Type string
 
@@ -14,9 +16,11 @@ type Token struct {
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // only emitted by lexers that keep comments
 
 	IDENT  = "IDENT"  // add, foobar, x, y, ...
 	INT    = "INT"    // 123456
+	FLOAT  = "FLOAT"  // 1.5, 1e-3
 	STRING = "STRING" // "hello world"
 
 	ASSIGN   = "="
@@ -25,15 +29,29 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
 
-	LT = "<"
-	GT = ">"
+	LT  = "<"
+	GT  = ">"
+	LTE = "<="
+	GTE = ">="
 
 	EQ  = "=="
 	NEQ = "!="
 
+	AND = "&&"
+	OR  = "||"
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -49,6 +67,18 @@ const (
 	IF       = "if"
 	ELSE     = "else"
 	RETURN   = "return"
+	WHILE    = "while"
+	FOR      = "for"
+	IN       = "in"
+	BREAK    = "break"
+	CONTINUE = "continue"
+	IMPORT   = "import"
+	EXPORT   = "export"
+	MACRO    = "macro"
+	TRY      = "try"
+	CATCH    = "catch"
+	FINALLY  = "finally"
+	THROW    = "throw"
 )
 
 // New initializes a Token with a string.
@@ -56,8 +86,8 @@ func New(t Type, s string, row, col int) Token {
 	return Token{t, s, row, col}
 }
 
-// NewC initializes a Token with a character in byte.
-func NewC(t Type, ch byte, row, col int) Token {
+// NewC initializes a Token with a character.
+func NewC(t Type, ch rune, row, col int) Token {
 	return New(t, string(ch), row, col)
 }
 
@@ -69,6 +99,28 @@ var keywords = map[string]Type{
 	IF:       IF,
 	ELSE:     ELSE,
 	RETURN:   RETURN,
+	WHILE:    WHILE,
+	FOR:      FOR,
+	IN:       IN,
+	BREAK:    BREAK,
+	CONTINUE: CONTINUE,
+	IMPORT:   IMPORT,
+	EXPORT:   EXPORT,
+	MACRO:    MACRO,
+	TRY:      TRY,
+	CATCH:    CATCH,
+	FINALLY:  FINALLY,
+	THROW:    THROW,
+}
+
+// Keywords returns the keywords in sorted order.
+func Keywords() []string {
+	kws := make([]string, 0, len(keywords))
+	for kw := range keywords {
+		kws = append(kws, kw)
+	}
+	sort.Strings(kws)
+	return kws
 }
 
 // LookupIdent finds type of an identifier.
@@ -0,0 +1,23 @@
+package vm
+
+import (
+	"github.com/ebiiim/monkey/code"
+	"github.com/ebiiim/monkey/object"
+)
+
+// Frame represents a call frame.
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+// NewFrame initializes a Frame.
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+// Instructions returns instructions of the function.
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,430 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ebiiim/monkey/code"
+	"github.com/ebiiim/monkey/compiler"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/object"
+)
+
+// Size limits.
+const (
+	StackSize   = 2048
+	GlobalsSize = 65536
+	MaxFrames   = 1024
+)
+
+// Errors
+var (
+	ErrStackOverflow = errors.New("stack overflow")
+)
+
+// Global objects are shared with evaluator so that both backends give the same results.
+var (
+	NULL  = evaluator.NULL
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+// VM runs Bytecode.
+type VM struct {
+	constants []object.Object
+	globals   []object.Object
+
+	stack []object.Object
+	sp    int // points to the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object
+}
+
+// New initializes a VM.
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, make([]object.Object, GlobalsSize))
+}
+
+// NewWithGlobalsStore initializes a VM that shares globals with a previous run (e.g. in a REPL).
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn}, 0)
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     globals,
+		stack:       make([]object.Object, StackSize),
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// LastPoppedStackElem returns the value of the last expression statement.
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+func (vm *VM) currentFrame() *Frame { return vm.frames[vm.framesIndex-1] }
+
+func (vm *VM) pushFrame(f *Frame) error {
+	if vm.framesIndex >= MaxFrames {
+		return fmt.Errorf("%w: max frames %d", ErrStackOverflow, MaxFrames)
+	}
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run runs the Bytecode.
+// Runtime errors wrap the same error values as evaluator.
+func (vm *VM) Run() error {
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+		ip := vm.currentFrame().ip
+		ins := vm.currentFrame().Instructions()
+		op := code.Opcode(ins[ip])
+
+		var err error
+		switch op {
+		case code.OpConstant:
+			idx := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[idx])
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterThanOrEqual, code.OpLessThanOrEqual:
+			err = vm.executeBinaryOperation(op)
+		case code.OpTrue:
+			err = vm.push(TRUE)
+		case code.OpFalse:
+			err = vm.push(FALSE)
+		case code.OpNull:
+			err = vm.push(NULL)
+		case code.OpBang:
+			err = vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop())))
+		case code.OpMinus:
+			err = vm.executeMinusOperator()
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			if !isTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpSetGlobal:
+			idx := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[idx] = vm.pop()
+		case code.OpGetGlobal:
+			idx := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			if vm.globals[idx] == nil {
+				return fmt.Errorf("%w: global#%d", evaluator.ErrIdentifierNotFound, idx)
+			}
+			err = vm.push(vm.globals[idx])
+		case code.OpSetLocal:
+			idx := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			vm.stack[vm.currentFrame().basePointer+int(idx)] = vm.pop()
+		case code.OpGetLocal:
+			idx := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.push(vm.stack[vm.currentFrame().basePointer+int(idx)])
+		case code.OpGetFree:
+			idx := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.push(vm.currentFrame().cl.Free[idx])
+		case code.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().cl)
+		case code.OpArray:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			elems := make([]object.Object, n)
+			copy(elems, vm.stack[vm.sp-n:vm.sp])
+			vm.sp -= n
+			err = vm.push(&object.Array{Elements: elems})
+		case code.OpHash:
+			n := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			var hash object.Object
+			hash, err = vm.buildHash(vm.sp-n, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp -= n
+			err = vm.push(hash)
+		case code.OpIndex:
+			idx := vm.pop()
+			left := vm.pop()
+			err = vm.executeIndexExpression(left, idx)
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.executeCall(int(numArgs))
+		case code.OpReturnValue:
+			val := vm.pop()
+			if vm.framesIndex == 1 { // return at top level
+				vm.lastPopped = val
+				return nil
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(val)
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(NULL)
+		case code.OpClosure:
+			idx := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(idx), int(numFree))
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return fmt.Errorf("%w: stack size %d", ErrStackOverflow, StackSize)
+	}
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+	lt, rt := left.Type(), right.Type()
+	switch {
+	case lt == object.INTEGER_OBJ && rt == object.INTEGER_OBJ:
+		return vm.executeIntegerOperation(op, left, right)
+	case lt == object.STRING_OBJ && rt == object.STRING_OBJ:
+		return vm.executeStringOperation(op, left, right)
+	// compare memory addresses like evaluator does
+	case op == code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case op == code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	case lt != rt:
+		return newError(evaluator.ErrTypeMismatch, "%s %s %s", lt, operators[op], rt)
+	default:
+		return newError(evaluator.ErrUnknownOperator, "%s %s %s", lt, operators[op], rt)
+	}
+}
+
+var operators = map[code.Opcode]string{
+	code.OpAdd:                "+",
+	code.OpSub:                "-",
+	code.OpMul:                "*",
+	code.OpDiv:                "/",
+	code.OpMod:                "%",
+	code.OpEqual:              "==",
+	code.OpNotEqual:           "!=",
+	code.OpGreaterThan:        ">",
+	code.OpLessThan:           "<",
+	code.OpGreaterThanOrEqual: ">=",
+	code.OpLessThanOrEqual:    "<=",
+}
+
+func (vm *VM) executeIntegerOperation(op code.Opcode, left, right object.Object) error {
+	l := left.(*object.Integer).Value
+	r := right.(*object.Integer).Value
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.Integer{Value: l + r})
+	case code.OpSub:
+		return vm.push(&object.Integer{Value: l - r})
+	case code.OpMul:
+		return vm.push(&object.Integer{Value: l * r})
+	case code.OpDiv:
+		if r == 0 {
+			return newError(evaluator.ErrDivisionByZero, "%d / %d", l, r)
+		}
+		return vm.push(&object.Integer{Value: l / r})
+	case code.OpMod:
+		if r == 0 {
+			return newError(evaluator.ErrDivisionByZero, "%d %% %d", l, r)
+		}
+		return vm.push(&object.Integer{Value: l % r})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(l == r))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(l != r))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(l > r))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(l < r))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(l >= r))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(l <= r))
+	default:
+		return newError(evaluator.ErrUnknownOperator, "%s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeStringOperation(op code.Opcode, left, right object.Object) error {
+	l := left.(*object.String).Value
+	r := right.(*object.String).Value
+	switch op {
+	case code.OpAdd:
+		return vm.push(&object.String{Value: l + r})
+	case code.OpEqual:
+		return vm.push(nativeBoolToBooleanObject(l == r))
+	case code.OpNotEqual:
+		return vm.push(nativeBoolToBooleanObject(l != r))
+	case code.OpGreaterThan:
+		return vm.push(nativeBoolToBooleanObject(l > r))
+	case code.OpLessThan:
+		return vm.push(nativeBoolToBooleanObject(l < r))
+	case code.OpGreaterThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(l >= r))
+	case code.OpLessThanOrEqual:
+		return vm.push(nativeBoolToBooleanObject(l <= r))
+	default:
+		return newError(evaluator.ErrUnknownOperator, "%s %s %s", left.Type(), operators[op], right.Type())
+	}
+}
+
+func (vm *VM) executeMinusOperator() error {
+	right := vm.pop()
+	if right.Type() != object.INTEGER_OBJ {
+		return newError(evaluator.ErrUnknownOperator, "-%s", right.Type())
+	}
+	return vm.push(&object.Integer{Value: -right.(*object.Integer).Value})
+}
+
+func (vm *VM) buildHash(start, end int) (object.Object, error) {
+	hash := object.NewHash()
+	for i := start; i < end; i += 2 {
+		key, ok := vm.stack[i].(object.Hashable)
+		if !ok {
+			return nil, newError(evaluator.ErrUnusableAsHashKey, "%s", vm.stack[i].Type())
+		}
+		hash.Set(key, vm.stack[i+1])
+	}
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		elems := left.(*object.Array).Elements
+		i := index.(*object.Integer).Value
+		if i < 0 || i > int64(len(elems)-1) {
+			return vm.push(NULL)
+		}
+		return vm.push(elems[i])
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(evaluator.ErrUnusableAsHashKey, "%s", index.Type())
+		}
+		val, ok := left.(*object.Hash).Get(key)
+		if !ok {
+			return vm.push(NULL)
+		}
+		return vm.push(val)
+	default:
+		return newError(evaluator.ErrIndexOperatorNotSupported, "%s", left.Type())
+	}
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Fn(args...)
+		vm.sp = vm.sp - numArgs - 1
+		if errObj, ok := result.(*object.Error); ok {
+			return errObj.Message
+		}
+		if result == nil {
+			result = NULL
+		}
+		return vm.push(result)
+	default:
+		return newError(evaluator.ErrIsNotFunction, "%s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	if want := cl.Fn.NumParameters; numArgs < want {
+		return newError(evaluator.ErrTooFewArgs, "want=%d got=%d", want, numArgs)
+	} else if numArgs > want {
+		return newError(evaluator.ErrTooManyArgs, "want=%d got=%d", want, numArgs)
+	}
+	frame := NewFrame(cl, vm.sp-numArgs)
+	if err := vm.pushFrame(frame); err != nil {
+		return err
+	}
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	if vm.sp >= StackSize {
+		return fmt.Errorf("%w: stack size %d", ErrStackOverflow, StackSize)
+	}
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) error {
+	fn, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", vm.constants[constIndex])
+	}
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp -= numFree
+	return vm.push(&object.Closure{Fn: fn, Free: free})
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func nativeBoolToBooleanObject(v bool) object.Object {
+	if v {
+		return TRUE
+	}
+	return FALSE
+}
+
+func newError(errType error, format string, a ...interface{}) error {
+	return fmt.Errorf("%w: %s", errType, fmt.Sprintf(format, a...))
+}
@@ -0,0 +1,290 @@
+package vm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ebiiim/monkey/ast"
+	"github.com/ebiiim/monkey/compiler"
+	"github.com/ebiiim/monkey/evaluator"
+	"github.com/ebiiim/monkey/lexer"
+	"github.com/ebiiim/monkey/object"
+	"github.com/ebiiim/monkey/parser"
+	"github.com/ebiiim/monkey/vm"
+)
+
+// TestSameAsEvaluator runs inputs of the evaluator tests with both backends and compares the results.
+func TestSameAsEvaluator(t *testing.T) {
+	cases := []string{
+		// integers
+		"5", "10", "-5", "-10",
+		"5 + 5 + 5 + 5 - 10",
+		"2 * 2 * 2 * 2 * 2",
+		"5 * 2 + 10",
+		"5 + 10 * 10",
+		"20 + 2 * -10",
+		"50 / 2 * 2 + 10",
+		"2 * (5 + 10)",
+		"3 * 3 * 3 + 10",
+		"3 * (3 * 3) + 10",
+		"(5 + 10 * 2 + 15 / 3) * 2 - 10",
+		// booleans
+		"true", "false",
+		"1 < 2", "1 > 2", "1 < 1", "1 > 1",
+		"1 == 1", "1 != 1", "1 == 2", "1 != 2",
+		"true == true", "false == false", "true == false", "true != false", "false != true",
+		"(1 < 2) == true", "(1 < 2) == false", "(1 > 2) == true", "(1 > 2) == false",
+		"!true", "!false", "!5", "!!true", "!!false", "!!5",
+		"1 <= 1", "2 <= 1", "1 >= 2", "2 >= 2", "7 % 3", "-7 % 3", "5 % 0",
+		`"a" < "b"`, `"abc" >= "abd"`, `"a" % "b"`,
+		"true && true", "true && false", "false && true", "false || true", "false || false", "true || false",
+		`1 && "a"`, "if (false) { 1 } || 0", "true && foobar",
+		"1 < 2 && 2 < 3 || false",
+		// conditionals
+		"if (true) { 10 }",
+		"if (false) { 10 }",
+		"if (1) { 10 }",
+		"if (1 < 2) { 10 }",
+		"if (1 > 2) { 10 }",
+		"if (1 < 2) { 10 } else { 20 }",
+		"if (1 > 2) { 10 } else { 20 }",
+		"if ((if (false) { 10 })) { 10 } else { 20 }",
+		// return
+		"return 10;",
+		"return 10; 9;",
+		"return 2 * 5; 9;",
+		"9; return 2 * 5; 9;",
+		"if (10 > 1) { return 10; } return 1;",
+		"if (10 > 1) { if (10 > 1) { return 1; 2; } return 3; }",
+		// errors
+		"5 + true;",
+		"5 + true; 5;",
+		"-true",
+		"true + false;",
+		"5; true + false; 5",
+		"if (10 > 1) { true + false; }",
+		"if (10 > 1) { if (10 > 1) { return true + false; 1; } return 1; }",
+		"foobar;",
+		`"hello " - "world";`,
+		`{"name": "Monkey"}[fn(x) { x }];`,
+		`{fn(x) { x }: 1};`,
+		"1 / 0",
+		"let zero = 0; 10 / zero; 5",
+		// let
+		"let a = 5; a;",
+		"let a = 5 * 5; a;",
+		"let a = 5; let b = a; b;",
+		"let a = 5; let b = a; let c = a + b + 5; c;",
+		// functions
+		"let identity = fn(x) { x; }; identity(5);",
+		"let identity = fn(x) { return x;}; identity(5);",
+		"let double = fn(x) { x * 2; }; double(5);",
+		"let add = fn(x, y) { x + y; }; add(5, 5);",
+		"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+		"fn(x) { x; }(5)",
+		"let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(10); ",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15)",
+		"let f = fn() { let countDown = fn(x) { if (x == 0) { 0 } else { countDown(x - 1) } }; countDown(5) }; f()",
+		"1(2)",
+		// strings
+		`"hello world"`,
+		`"hello" + " " + "world"`,
+		// arrays
+		"[1, 2 * 2, 3 + 3]",
+		"[1, 2, 3][0]", "[1, 2, 3][1]",
+		"let i = 0; [1][i];",
+		"[1, 2, 3][1 + 1];",
+		"let myArray = [1, 2, 3]; myArray[2]",
+		"let arr = [1, 2, 3]; arr[1] + arr[2];",
+		"let arr = [1, 2, 3]; let i = arr[0]; arr[i]",
+		"[1, 2, 3][3]", "[1, 2, 3][-1]",
+		"1[0]",
+		// hashes
+		`{"foo": 5}["foo"]`, `{"foo": 5}["bar"]`, `let key = "foo"; {"foo": 5}[key]`,
+		`{}["foo"]`, `{5: 5}[5]`, `{true: 5}[true]`, `{false: 5}[false]`, `{"a": 1, "a": 2}["a"]`,
+		`let two = "two"; {"one": 10 - 9, two: 1 + 1, "thr" + "ee": 6 / 2, 4: 4, true: 5, false: 6}`,
+		// builtins
+		`len("")`, `len("four")`, `len("hello world")`, `len([])`, `len([1])`, `len([1, 2])`,
+		`len(["hello world", 2 * 2 * 2 * 2, fn(x, y){ x * x + y * y }])`,
+		`len(1)`, `len(1, 2)`, `len()`,
+		`first([1])`, `first(["hello world", 2])`, `first([])`, `first()`, `first(1)`,
+		`last([1, 2, 3])`, `last([])`, `last(1)`,
+		`rest([1, 2, 3])`, `rest([])`, `rest(1)`,
+		`push([1], 2)`, `push([], 1)`, `push(1, 1)`,
+		`pop([1, 2, 3])`, `pop([])`, `pop(1)`,
+		`keys({"a": 1, 2: true})`, `values({"a": 1, 2: true})`,
+		`put({}, "a", 1)["a"]`, `len(delete({"a": 1, "b": 2}, "a"))`, `put({}, fn(){}, 1)`,
+		`let len = fn(x) { 42 }; len([])`,
+	}
+	for _, input := range cases {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			program := parse(t, input)
+			want := evaluator.Eval(program, object.NewEnvironment())
+
+			comp := compiler.New()
+			if err := comp.Compile(program); err != nil {
+				assertSameError(t, want, err)
+				return
+			}
+			machine := vm.New(comp.Bytecode())
+			if err := machine.Run(); err != nil {
+				assertSameError(t, want, err)
+				return
+			}
+			got := machine.LastPoppedStackElem()
+			if errObj, ok := want.(*object.Error); ok {
+				t.Fatalf("want error %v got=%s", errObj.Message, got.Inspect())
+			}
+			if got.Type() != want.Type() || got.Inspect() != want.Inspect() {
+				t.Errorf("want=%s (%s) got=%s (%s)", want.Inspect(), want.Type(), got.Inspect(), got.Type())
+			}
+			if want.Type() == object.NULL_OBJ && got != evaluator.NULL {
+				t.Errorf("got a NULL that is not evaluator.NULL")
+			}
+		})
+	}
+}
+
+func TestClosures(t *testing.T) {
+	cases := []struct {
+		input string
+		want  int64
+	}{
+		{`let newClosure = fn(a, b) { let c = a + b; fn(d) { let e = d + c; fn(f) { e + f } } };
+let closure = newClosure(1, 2);
+closure(3)(4)`, 10},
+		{`let wrapper = fn() { let countDown = fn(x) { if (x == 0) { return 0; } else { countDown(x - 1); } }; countDown(1); };
+wrapper();`, 0},
+		{`let noReturn = fn() { }; let x = noReturn(); if (x) { 1 } else { 2 }`, 2},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			got, err := run(t, c.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			i, ok := got.(*object.Integer)
+			if !ok || i.Value != c.want {
+				t.Errorf("want=%d got=%v", c.want, got)
+			}
+		})
+	}
+}
+
+func TestWrongNumberOfArguments(t *testing.T) {
+	cases := []struct {
+		input string
+		want  error
+	}{
+		{`fn() { 1; }(1);`, evaluator.ErrTooManyArgs},
+		{`fn(a) { a; }();`, evaluator.ErrTooFewArgs},
+		{`fn(a, b) { a + b; }(1);`, evaluator.ErrTooFewArgs},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.input, func(t *testing.T) {
+			_, err := run(t, c.input)
+			if !errors.Is(err, c.want) {
+				t.Errorf("want=%v got=%v", c.want, err)
+			}
+		})
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	_, err := run(t, `let f = fn(x) { f(x + 1) + 1 }; f(0)`)
+	if !errors.Is(err, vm.ErrStackOverflow) {
+		t.Errorf("want=%v got=%v", vm.ErrStackOverflow, err)
+	}
+}
+
+func TestGlobalsStore(t *testing.T) {
+	globals := make([]object.Object, vm.GlobalsSize)
+	symbols := compiler.NewSymbolTable()
+	var constants []object.Object
+	for _, c := range []struct {
+		input string
+		want  string
+	}{
+		{"let a = 1;", ""},
+		{"let b = fn(x) { a + x };", ""},
+		{"b(2)", "3"},
+	} {
+		comp := compiler.NewWithState(symbols, constants)
+		if err := comp.Compile(parse(t, c.input)); err != nil {
+			t.Fatal(err)
+		}
+		constants = comp.Bytecode().Constants
+		machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+		if err := machine.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if c.want == "" {
+			continue
+		}
+		if got := machine.LastPoppedStackElem(); got == nil || got.Inspect() != c.want {
+			t.Errorf("%s: want=%s got=%v", c.input, c.want, got)
+		}
+	}
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return program
+}
+
+func run(t *testing.T, input string) (object.Object, error) {
+	t.Helper()
+	comp := compiler.New()
+	if err := comp.Compile(parse(t, input)); err != nil {
+		return nil, err
+	}
+	machine := vm.New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+func assertSameError(t *testing.T, want object.Object, err error) {
+	t.Helper()
+	errObj, ok := want.(*object.Error)
+	if !ok {
+		t.Fatalf("unexpected error %v (want=%s)", err, want.Inspect())
+	}
+	if errors.Unwrap(err) != errors.Unwrap(errObj.Message) {
+		t.Errorf("wrong error type want=%v got=%v", errors.Unwrap(errObj.Message), errors.Unwrap(err))
+	}
+	if err.Error() != errObj.Message.Error() {
+		t.Errorf("wrong error message want=%s got=%s", errObj.Message, err)
+	}
+}
+
+const benchFib = `let fib = fn(x) { if (x < 2) { x } else { fib(x - 1) + fib(x - 2) } }; fib(20);`
+
+func BenchmarkFibonacciVM(b *testing.B) {
+	program := parser.New(lexer.New(benchFib)).ParseProgram()
+	for i := 0; i < b.N; i++ {
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			b.Fatal(err)
+		}
+		if err := vm.New(comp.Bytecode()).Run(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFibonacciEvaluator(b *testing.B) {
+	program := parser.New(lexer.New(benchFib)).ParseProgram()
+	for i := 0; i < b.N; i++ {
+		evaluator.Eval(program, object.NewEnvironment())
+	}
+}